@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// aliasFile is the name of the file in repoParent that holds the user's version aliases,
+// one "<name> <version>" line each.
+const aliasFile = "aliases"
+
+// readAliases returns the aliases, mapping names to versions.
+func readAliases() (map[string]string, error) {
+	path := filepath.Join(repoParent(), aliasFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read aliases: %v", err)
+	}
+	aliases := map[string]string{}
+	scan := bufio.NewScanner(bytes.NewReader(data))
+	for scan.Scan() {
+		ff := strings.Fields(scan.Text())
+		if len(ff) == 0 {
+			continue
+		}
+		if len(ff) != 2 {
+			return nil, fmt.Errorf("malformed line in %s: %q", path, scan.Text())
+		}
+		aliases[ff[0]] = ff[1]
+	}
+	return aliases, nil
+}
+
+// writeAliases replaces the aliases with aliases.
+func writeAliases(aliases map[string]string) error {
+	var names []string
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s %s\n", name, aliases[name])
+	}
+	path := filepath.Join(repoParent(), aliasFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write alongside and rename into place, so that readers never see a partial file.
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write aliases: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not write aliases: %v", err)
+	}
+	return nil
+}
+
+// expandAlias returns the version that the alias name stands for.
+// It reports false if name is not an alias.
+func expandAlias(ctx context.Context, name string) (string, bool, error) {
+	aliases, err := readAliases()
+	if err != nil {
+		return "", false, err
+	}
+	target, ok := aliases[name]
+	if !ok {
+		return "", false, nil
+	}
+	if target == "latest" {
+		ref, err := latest(ctx)
+		return ref, true, err
+	}
+	if ref, err := toolchain.Normalize(target); err == nil {
+		return ref, true, nil
+	}
+	// Otherwise, target is the directory of a ref install, made by install -ref -as.
+	if _, exist := cmdgo(repoParent(), target); !exist {
+		return "", true, fmt.Errorf("alias %s names %s, which is not installed", name, target)
+	}
+	return target, true, nil
+}
+
+// checkAliasName reports an error if name can't be an alias,
+// because it is a command or could be mistaken for a version.
+func checkAliasName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	reserved := append([]string{"listdl", "export", "unpack", "help", "latest", tipName, "master", release14}, commands...)
+	for _, cmd := range reserved {
+		if name == cmd {
+			return fmt.Errorf("cannot use %q as an alias: it is a goversion command or version", name)
+		}
+	}
+	if _, err := toolchain.Normalize(name); err != toolchain.ErrNotVersion {
+		return fmt.Errorf("cannot use %q as an alias: it looks like a Go version", name)
+	}
+	return nil
+}
+
+// alias makes name an alias for target, which is a version, latest,
+// or the name of an installed ref, such as one built by install -ref.
+// An alias for latest always means the newest stable release.
+func alias(ctx context.Context, name, target string) error {
+	if err := checkAliasName(name); err != nil {
+		return err
+	}
+	if target != "latest" {
+		ref, err := version(ctx, target)
+		if err == errNotVersion {
+			if _, exist := cmdgo(repoParent(), target); !exist {
+				return fmt.Errorf("%q is neither a Go version nor an installed ref", target)
+			}
+			ref, err = target, nil
+		}
+		if err != nil {
+			return err
+		}
+		target = ref
+	}
+	aliases, err := readAliases()
+	if err != nil {
+		return err
+	}
+	if prev, ok := aliases[name]; ok && prev != target {
+		logf("%s changed from %s to %s", name, prev, target)
+	} else {
+		logf("%s is %s", name, target)
+	}
+	aliases[name] = target
+	return writeAliases(aliases)
+}
+
+// unalias deletes the alias name.
+func unalias(name string) error {
+	aliases, err := readAliases()
+	if err != nil {
+		return err
+	}
+	if _, ok := aliases[name]; !ok {
+		return fmt.Errorf("no alias %q", name)
+	}
+	delete(aliases, name)
+	return writeAliases(aliases)
+}
+
+// printAliases prints the aliases named in names, or all of them if names is empty.
+func printAliases(names ...string) error {
+	aliases, err := readAliases()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		target, ok := aliases[name]
+		if !ok {
+			return fmt.Errorf("no alias %q", name)
+		}
+		fmt.Printf("%s %s\n", name, target)
+	}
+	return nil
+}
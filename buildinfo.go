@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Release builds set these with -ldflags, as in
+//
+//	go build -ldflags "-X main.buildVersion=v1.2.3 -X main.buildCommit=abc123 -X main.buildDate=2024-01-02"
+var (
+	buildVersion string
+	buildCommit  string
+	buildDate    string
+)
+
+// toolVersion returns the version, commit, and build date of goversion itself.
+// Values not set at link time come from the build info that the go command records,
+// so that copies installed with go install still know where they came from.
+// Unknown values are empty, except that the version defaults to "devel".
+func toolVersion() (vers, commit, date string) {
+	vers, commit, date = buildVersion, buildCommit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if vers == "" && info.Main.Version != "(devel)" {
+			vers = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if commit == "" {
+					commit = s.Value
+				}
+			case "vcs.time":
+				if date == "" {
+					date = s.Value
+				}
+			}
+		}
+	}
+	if vers == "" {
+		vers = "devel"
+	}
+	return vers, commit, date
+}
+
+// printToolVersion prints the version of goversion itself, for bug reports.
+func printToolVersion() {
+	vers, commit, date := toolVersion()
+	fmt.Printf("goversion %s", vers)
+	if commit != "" {
+		fmt.Printf(" commit %s", commit)
+	}
+	if date != "" {
+		fmt.Printf(" built %s", date)
+	}
+	fmt.Printf(" %s/%s %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheEntries returns the paths of goversion's cached state:
+// the download index, the Go mirror, and leftovers from interrupted runs,
+// such as download directories and partial extractions.
+// The mirror is included only if mirror is set.
+func cacheEntries(mirror bool) ([]string, error) {
+	parent := repoParent()
+	paths := []string{filepath.Join(parent, "dl-index.txt")}
+	if mirror {
+		paths = append(paths, filepath.Join(parent, "go.mirror"))
+	}
+	for _, pattern := range []string{
+		filepath.Join(parent, ".download*"),
+		filepath.Join(parent, ".tarball*"),
+		filepath.Join(parent, ".pkg*"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	var exist []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			exist = append(exist, path)
+		}
+	}
+	return exist, nil
+}
+
+// cache runs goversion cache <action>.
+func cache(action string, mirror bool) error {
+	parent := repoParent()
+	switch action {
+	case "path":
+		fmt.Printf("root      %s\n", parent)
+		fmt.Printf("index     %s\n", filepath.Join(parent, "dl-index.txt"))
+		fmt.Printf("mirror    %s\n", filepath.Join(parent, "go.mirror"))
+		fmt.Printf("downloads %s\n", filepath.Join(parent, ".download*"))
+		return nil
+	case "size":
+		paths, err := cacheEntries(true)
+		if err != nil {
+			return err
+		}
+		var total int64
+		for _, path := range paths {
+			n := dirsize(path)
+			total += n
+			fmt.Printf("%10s  %s\n", fmtsize(n), path)
+		}
+		fmt.Printf("%10s  total\n", fmtsize(total))
+		return nil
+	case "clean":
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		paths, err := cacheEntries(mirror)
+		if err != nil {
+			return err
+		}
+		var total int64
+		for _, path := range paths {
+			n := dirsize(path)
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("could not remove %s: %v", path, err)
+			}
+			vlogf("removed %s", path)
+			total += n
+		}
+		logf("freed %s", fmtsize(total))
+		return nil
+	}
+	printUsage()
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commands are the documented subcommands, for shell completion.
+var commands = []string{
+	"list",
+	"installed",
+	"install",
+	"uninstall",
+	"prune",
+	"gc",
+	"default",
+	"use",
+	"which",
+	"alias",
+	"env",
+	"run",
+	"exec-all",
+	"tip",
+	"update",
+	"completion",
+	"freeze",
+	"verify",
+	"cache",
+	"doctor",
+	"self-update",
+	"version",
+}
+
+// completion prints a completion script for shell.
+// The scripts complete subcommands and ask goversion itself for versions:
+// the remote tags for install and the installed versions everywhere else.
+func completion(shell string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("unsupported shell %q; want bash, zsh, or fish", shell)
+	}
+	fmt.Print(strings.Replace(script, "@COMMANDS@", strings.Join(commands, " "), -1))
+	return nil
+}
+
+const bashCompletion = `# bash completion for goversion
+# Load with: source <(goversion completion bash)
+_goversion() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "@COMMANDS@ $(goversion installed 2>/dev/null)" -- "$cur"))
+		return
+	fi
+	if [ "$COMP_CWORD" -eq 2 ]; then
+		case "${COMP_WORDS[1]}" in
+		install)
+			COMPREPLY=($(compgen -W "$(goversion list 2>/dev/null)" -- "$cur"))
+			;;
+		uninstall|default|use|which|env|run)
+			COMPREPLY=($(compgen -W "$(goversion installed 2>/dev/null)" -- "$cur"))
+			;;
+		completion)
+			COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+			;;
+		esac
+	fi
+	# The version of goversion alias <name> <version>.
+	if [ "$COMP_CWORD" -eq 3 ] && [ "${COMP_WORDS[1]}" = alias ]; then
+		COMPREPLY=($(compgen -W "$(goversion installed 2>/dev/null)" -- "$cur"))
+	fi
+}
+complete -o default -F _goversion goversion
+`
+
+const zshCompletion = `#compdef goversion
+# zsh completion for goversion
+# Load with: source <(goversion completion zsh)
+_goversion() {
+	if (( CURRENT == 2 )); then
+		compadd @COMMANDS@ ${(f)"$(goversion installed 2>/dev/null)"}
+		return
+	fi
+	if (( CURRENT == 3 )); then
+		case $words[2] in
+		install)
+			compadd ${(f)"$(goversion list 2>/dev/null)"}
+			;;
+		uninstall|default|use|which|env|run)
+			compadd ${(f)"$(goversion installed 2>/dev/null)"}
+			;;
+		completion)
+			compadd bash zsh fish
+			;;
+		*)
+			_files
+			;;
+		esac
+		return
+	fi
+	# The version of goversion alias <name> <version>.
+	if (( CURRENT == 4 )) && [[ $words[2] == alias ]]; then
+		compadd ${(f)"$(goversion installed 2>/dev/null)"}
+		return
+	fi
+	_files
+}
+compdef _goversion goversion
+`
+
+const fishCompletion = `# fish completion for goversion
+# Load with: goversion completion fish | source
+set -l commands @COMMANDS@
+complete -c goversion -n "not __fish_seen_subcommand_from $commands" -a "$commands"
+complete -c goversion -n "not __fish_seen_subcommand_from $commands" -a "(goversion installed 2>/dev/null)"
+complete -c goversion -n "__fish_seen_subcommand_from install" -a "(goversion list 2>/dev/null)"
+complete -c goversion -n "__fish_seen_subcommand_from uninstall default use which env run" -a "(goversion installed 2>/dev/null)"
+# The version of goversion alias <name> <version>.
+complete -c goversion -n "__fish_seen_subcommand_from alias; and test (count (commandline -opc)) -eq 3" -a "(goversion installed 2>/dev/null)"
+complete -c goversion -n "__fish_seen_subcommand_from completion" -a "bash zsh fish"
+`
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The config file sets defaults for settings that users would otherwise
+// pass on every command line. It holds key = value lines;
+// blank lines and lines starting with # are ignored.
+// Flags override environment variables, which override the config file.
+
+// configKeys maps the keys the config file may set
+// to the environment variables or flags that override them.
+var configKeys = map[string]string{
+	"root":               "GOVERSION_ROOT",
+	"git-remote":         "GOVERSION_GIT_REMOTE",
+	"dl-base":            "GOVERSION_DL_BASE",
+	"dl-index":           "GOVERSION_DL_INDEX",
+	"offline":            "-offline",
+	"skip-verify":        "-skip-verify",
+	"mirror-from-github": "-mirror-from-github",
+}
+
+var (
+	config       map[string]string // settings from the config file
+	configFile   string            // path of the config file, if one was read
+	cmdlineFlags map[string]bool   // global flags given on the command line
+)
+
+// configPath returns the path of the config file:
+// $GOVERSION_CONFIG if set, and otherwise goversion/config in the user config directory,
+// such as ~/.config/goversion/config on Linux.
+// It returns "" if there is none.
+func configPath() string {
+	if path := os.Getenv("GOVERSION_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "goversion", "config")
+}
+
+// loadConfig reads the config file, if there is one,
+// and applies its settings for flags that were not given on the command line.
+// It is called once, at startup.
+func loadConfig() error {
+	// Record what the command line set before the config file sets more.
+	cmdlineFlags = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { cmdlineFlags[f.Name] = true })
+	path := configPath()
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && os.Getenv("GOVERSION_CONFIG") == "" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read config file: %v", err)
+	}
+	defer f.Close()
+	config = map[string]string{}
+	configFile = path
+	scan := bufio.NewScanner(f)
+	for n := 1; scan.Scan(); n++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || val == "" {
+			return fmt.Errorf("%s:%d: want key = value", path, n)
+		}
+		if _, ok := configKeys[key]; !ok {
+			return fmt.Errorf("%s:%d: unknown setting %q", path, n, key)
+		}
+		config[key] = val
+	}
+	if err := scan.Err(); err != nil {
+		return fmt.Errorf("could not read config file: %v", err)
+	}
+
+	if mirror, _ := strconv.ParseBool(config["mirror-from-github"]); mirror && config["git-remote"] != "" {
+		return fmt.Errorf("%s: git-remote and mirror-from-github conflict; set one", path)
+	}
+	for key, val := range config {
+		name := strings.TrimPrefix(configKeys[key], "-")
+		if name == configKeys[key] || cmdlineFlags[name] {
+			continue
+		}
+		if err := flag.Set(name, val); err != nil {
+			return fmt.Errorf("invalid %s %q in %s: want true or false", key, val, path)
+		}
+	}
+	return nil
+}
+
+// setting returns the value of the environment variable key,
+// or if it is unset, of the config file setting it overrides,
+// along with a description of where the value came from for error messages.
+// It returns "" if neither is set.
+func setting(key string) (val, source string) {
+	if val := os.Getenv(key); val != "" {
+		return val, key
+	}
+	for ckey, env := range configKeys {
+		if env == key && config[ckey] != "" {
+			return config[ckey], ckey + " in " + configFile
+		}
+	}
+	return "", key
+}
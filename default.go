@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// current is the name of the symlink in repoParent that points at the default version.
+// On Windows, where symlinks need special privileges, it is a file containing the version instead.
+const current = toolchain.DefaultName
+
+// setDefault makes ref the default version.
+func setDefault(ref string) error {
+	parent := repoParent()
+	if _, err := goPath(ref); err != nil {
+		return err
+	}
+	prev := defaultVersion()
+	link := filepath.Join(parent, current)
+	if runtime.GOOS == "windows" {
+		if err := os.WriteFile(link, []byte(ref+"\n"), 0644); err != nil {
+			return fmt.Errorf("could not set default: %v", err)
+		}
+	} else {
+		// Create the new link alongside the old one and rename it into place,
+		// so that there is always a valid default.
+		tmp := link + ".new"
+		os.Remove(tmp)
+		if err := os.Symlink(ref, tmp); err != nil {
+			return fmt.Errorf("could not set default: %v", err)
+		}
+		if err := os.Rename(tmp, link); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("could not set default: %v", err)
+		}
+	}
+	if prev != "" && prev != ref {
+		logf("default changed from %s to %s", prev, ref)
+	} else {
+		logf("default is %s", ref)
+	}
+	return nil
+}
+
+// defaultVersion returns the default version, or "" if none has been set.
+func defaultVersion() string {
+	return manager().Default()
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+const (
+	dlindex = toolchain.DefaultIndex
+	dlbase  = toolchain.DefaultBase
+)
+
+var (
+	errNoBinary = toolchain.ErrNoBinary
+	errOffline  = errors.New("network access disabled by -offline")
+)
+
+var (
+	skipVerify = flag.Bool("skip-verify", false, "do not verify checksums of downloaded archives")
+	retries    = flag.Int("retries", 3, "number of times to retry failed network requests")
+	offline    = flag.Bool("offline", false, "never access the network; use only the Go mirror and cached data")
+)
+
+var httpTimeoutFlag = flag.Duration("http-timeout", 30*time.Second, "time limit for small network requests and for stalls in downloads; 0 means none")
+
+var (
+	clientOnce  sync.Once
+	client      *http.Client
+	httpTimeout time.Duration // set with client
+	clientErr   error
+)
+
+// httpClient returns the client to use for all network requests.
+// It honors HTTP_PROXY, HTTPS_PROXY, and NO_PROXY.
+// If GOVERSION_CACERT names a PEM file, its certificates are trusted
+// in addition to the system roots.
+// It also sets httpTimeout from -http-timeout,
+// or if that is not given, from GOVERSION_HTTP_TIMEOUT.
+func httpClient() (*http.Client, error) {
+	clientOnce.Do(func() {
+		httpTimeout = *httpTimeoutFlag
+		explicit := false
+		flag.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "http-timeout" })
+		if s := os.Getenv("GOVERSION_HTTP_TIMEOUT"); s != "" && !explicit {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				clientErr = fmt.Errorf("invalid GOVERSION_HTTP_TIMEOUT %q: want a duration such as 30s", s)
+				return
+			}
+			httpTimeout = d
+		}
+		// Even downloads, which may take much longer than httpTimeout,
+		// must connect and start responding within it.
+		dialer := &net.Dialer{Timeout: httpTimeout, KeepAlive: 30 * time.Second}
+		t := &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: httpTimeout,
+		}
+		if file := os.Getenv("GOVERSION_CACERT"); file != "" {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				clientErr = fmt.Errorf("could not read GOVERSION_CACERT: %v", err)
+				return
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				clientErr = fmt.Errorf("no certificates found in GOVERSION_CACERT=%s", file)
+				return
+			}
+			t.TLSClientConfig = &tls.Config{RootCAs: pool}
+		}
+		client = &http.Client{Transport: t}
+	})
+	return client, clientErr
+}
+
+// dlFileRE matches the file names of binary archives in the download index,
+// such as go1.21.0.linux-arm64.tar.gz and go1.2.2.darwin-386-osx10.6.tar.gz.
+// The submatches are the version, GOOS, arch, and an optional OS X release.
+var dlFileRE = regexp.MustCompile(`^(go\d+(?:\.\d+)*(?:(?:beta|rc)\d+)?)\.([a-z0-9]+)-([a-z0-9]+)(?:-(osx[0-9.]+))?(?:\.tar\.gz|\.zip)$`)
+
+// parseDlFile parses a URL or file name from the download index
+// and returns the version and platform of the binary archive it names.
+// It reports false for files we can't use directly,
+// such as installers, checksums, and source archives.
+func parseDlFile(line string) (vers string, p platform, ok bool) {
+	// Strip down to just the file name.
+	// For example, line may be
+	// https://storage.googleapis.com/golang/go1.2.2.darwin-386-osx10.6.tar.gz
+	file := line[strings.LastIndexByte(line, '/')+1:]
+	m := dlFileRE.FindStringSubmatch(file)
+	if m == nil {
+		return "", platform{}, false
+	}
+	vers, p.goos = m[1], m[2]
+	arch, osx := m[3], m[4]
+	// Assume no-one runs OS X 10.6 anymore.
+	if osx == "osx10.6" {
+		return "", platform{}, false
+	}
+	// Clean up arch to match GOARCH naming.
+	// go1.6beta1 has linux-arm and linux-arm6 downloads.
+	// Every other release has armv6l.
+	// Skip plain arm and then map arm6 and armv6l to arm.
+	switch arch {
+	case "arm":
+		return "", platform{}, false
+	case "arm6", "armv6l":
+		arch = "arm"
+	}
+	p.goarch = arch
+	return vers, p, true
+}
+
+// parseDlLine parses a line from the download index
+// and returns the version of the binary archive it names,
+// reporting false if the line is not a usable archive for goos/goarch.
+func parseDlLine(line, goos, goarch string) (version string, ok bool) {
+	vers, p, ok := parseDlFile(line)
+	if !ok || p != (platform{goos, goarch}) {
+		return "", false
+	}
+	return vers, true
+}
+
+// A statusError reports a response with a non-2xx status.
+type statusError struct {
+	url    string
+	status string // such as "404 Not Found"
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return "GET " + e.url + ": " + e.status
+}
+
+// get fetches url, retrying transient failures with exponential backoff and jitter.
+// Responses with a non-2xx status are reported as a *statusError.
+// A 404 is not retried: the file genuinely does not exist.
+// With -offline, get fails with errOffline.
+// Each attempt, including reading the body, must finish within httpTimeout,
+// so get is for small files such as the download index.
+func get(ctx context.Context, url string) (*http.Response, error) {
+	return doGet(ctx, url, nil, false)
+}
+
+// getStream is like get, for large files, and adds header to the request.
+// The server must start responding within httpTimeout,
+// but reading the body may take any amount of time.
+func getStream(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	return doGet(ctx, url, header, true)
+}
+
+func doGet(ctx context.Context, url string, header http.Header, stream bool) (*http.Response, error) {
+	if *offline {
+		return nil, errOffline
+	}
+	c, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+	client := *c
+	if !stream {
+		client.Timeout = httpTimeout
+	}
+	backoff := 500 * time.Millisecond
+	for try := 0; ; try++ {
+		vlogf("GET %s", url)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			if resp.StatusCode/100 == 2 {
+				return resp, nil
+			}
+			resp.Body.Close()
+			err = &statusError{url: url, status: resp.Status, code: resp.StatusCode}
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, err
+			}
+		}
+		if try >= *retries || ctx.Err() != nil {
+			return nil, err
+		}
+		d := backoff + time.Duration(rand.Int63n(int64(backoff)))
+		logf("%v; retrying in %v", err, d.Round(time.Millisecond))
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// dryRun is set for dry runs, such as install -n, which must leave the root untouched.
+var dryRun bool
+
+// openDlIndex returns the contents of the download index.
+// A copy is kept in the root so that -offline can use it later, except in dry runs.
+func openDlIndex(ctx context.Context) (io.ReadCloser, error) {
+	cache := filepath.Join(repoParent(), "dl-index.txt")
+	if *offline {
+		f, err := os.Open(cache)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("download index is not cached; run list -binary once without -offline")
+		}
+		return f, err
+	}
+	index, err := dlIndex()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := get(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cache), 0755); err == nil {
+		if err := os.WriteFile(cache, body, 0644); err != nil {
+			vlogf("could not cache download index: %v", err)
+		}
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+// getdlindex returns the URLs listed in the download index.
+func getdlindex(ctx context.Context) ([]string, error) {
+	r, err := openDlIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch download index: %v", err)
+	}
+	defer r.Close()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read download index: %v", err)
+	}
+	return strings.Fields(string(body)), nil
+}
+
+// selectBinary returns the URL of the binary archive of ref for p
+// in the configured download index, as toolchain.SelectArchive does.
+func selectBinary(ctx context.Context, ref string, p platform) (string, error) {
+	base, err := dlBase()
+	if err != nil {
+		return "", err
+	}
+	urls, err := getdlindex(ctx)
+	if err != nil {
+		return "", err
+	}
+	return toolchain.SelectArchive(urls, base, ref, p.goos, p.goarch)
+}
+
+// download fetches the binary archive of ref for p into dir
+// and returns the path to the downloaded file.
+// dir should be private to this run, such as one made by os.MkdirTemp,
+// so that concurrent installs never share a file.
+// Like selectBinary, it returns errNoBinary if there is no such archive.
+// The archive must match its published checksum, unless -skip-verify is set,
+// and pin, if pin is non-empty, regardless of -skip-verify.
+// download logs the archive's SHA256 digest, and returns it in hex, for pinning.
+func download(ctx context.Context, ref string, p platform, dir, pin string) (path, sum string, err error) {
+	url, err := selectBinary(ctx, ref, p)
+	if err != nil {
+		return "", "", err
+	}
+	path = filepath.Join(dir, url[strings.LastIndexByte(url, '/')+1:])
+	logEventf(logEvent{Level: "info", Version: ref, Phase: "download", Path: url}, "downloading %s", url)
+	// Stream into a temp file next to path and rename it into place once complete,
+	// so that an interrupted download never looks finished.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.partial")
+	if err != nil {
+		return "", "", fmt.Errorf("could not create temp file: %v", err)
+	}
+	// CreateTemp makes private files; the archive is nothing to hide.
+	err = f.Chmod(0644)
+	if err == nil {
+		err = fetch(ctx, url, f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		sum, err = fileSHA256(f.Name())
+	}
+	if err, ok := err.(*statusError); ok && err.code == http.StatusNotFound {
+		os.Remove(f.Name())
+		return "", "", fmt.Errorf("could not download %s: the download index lists it, but the server has no such file (%s)", url, err.status)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", fmt.Errorf("could not download %s: %v", url, err)
+	}
+	logEventf(logEvent{Level: "info", Version: ref, Phase: "download", Path: url}, "sha256 %s  %s", sum, filepath.Base(path))
+	if pin != "" && sum != pin {
+		os.Remove(f.Name())
+		return "", "", fmt.Errorf("checksum mismatch for %s: pinned %s, got %s", url, pin, sum)
+	}
+	if !*skipVerify {
+		want, err := checksum(ctx, url)
+		if err != nil {
+			os.Remove(f.Name())
+			return "", "", fmt.Errorf("could not verify %s: %v", url, err)
+		}
+		if sum != want {
+			os.Remove(f.Name())
+			return "", "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, want, sum)
+		}
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return "", "", fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return path, sum, nil
+}
+
+// fetch writes the body of url to f, which must be empty.
+// If the connection drops partway through, fetch resumes where it stopped
+// with a Range request, up to -retries times.
+// If-Range makes sure the pieces come from the same file:
+// if the server doesn't support ranges, or the file has changed,
+// it sends the whole file again, and fetch starts over.
+func fetch(ctx context.Context, url string, f *os.File) error {
+	var header http.Header // request header for resuming
+	var written int64      // bytes of the file in f
+	size := int64(-1)      // size of the whole file, if known
+	for try := 0; ; try++ {
+		// Cancel attempts that stall, so that they can be resumed.
+		actx, cancel := context.WithCancel(ctx)
+		resp, err := getStream(actx, url, header)
+		if serr, ok := err.(*statusError); ok && serr.code == http.StatusRequestedRangeNotSatisfiable {
+			// f somehow holds more than the server has. Start over.
+			header = nil
+			resp, err = getStream(actx, url, nil)
+		}
+		if err != nil {
+			cancel()
+			return err
+		}
+		if resp.StatusCode == http.StatusPartialContent {
+			if !resumes(resp, written, size) {
+				// Not the range we asked for. Ask for the whole file instead.
+				resp.Body.Close()
+				cancel()
+				if try >= *retries {
+					return fmt.Errorf("server sent an unexpected range (%s)", resp.Header.Get("Content-Range"))
+				}
+				header = nil
+				continue
+			}
+			vlogf("resuming at %s", fmtsize(written))
+		} else {
+			// A full response: discard anything already written.
+			if written > 0 {
+				vlogf("server sent the whole file; starting over")
+			}
+			if err := f.Truncate(0); err != nil {
+				resp.Body.Close()
+				cancel()
+				return err
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				resp.Body.Close()
+				cancel()
+				return err
+			}
+			written, size = 0, resp.ContentLength
+			// Only a strong validator guarantees byte-for-byte identical ranges.
+			header = http.Header{}
+			if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("If-Range", etag)
+			} else if mod := resp.Header.Get("Last-Modified"); mod != "" {
+				header.Set("If-Range", mod)
+			}
+		}
+		var body io.Reader = resp.Body
+		var stall *time.Timer
+		if httpTimeout > 0 {
+			stall = time.AfterFunc(httpTimeout, cancel)
+			body = &stallReader{body, stall}
+		}
+		var bar *progress
+		if !*quiet && !*logJSON && isTerminal(os.Stderr) {
+			bar = newProgress(body, size)
+			bar.n = written
+			body = bar
+		}
+		n, err := io.Copy(f, body)
+		written += n
+		if bar != nil {
+			bar.done()
+		}
+		resp.Body.Close()
+		if stall != nil && !stall.Stop() && err != nil && ctx.Err() == nil {
+			err = fmt.Errorf("no data received for %v", httpTimeout)
+		}
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if try >= *retries || ctx.Err() != nil {
+			return err
+		}
+		if header.Get("If-Range") == "" {
+			logf("%v; retrying", err)
+			header = nil
+			continue
+		}
+		logf("%v; resuming after %s", err, fmtsize(written))
+		header.Set("Range", fmt.Sprintf("bytes=%d-", written))
+	}
+}
+
+// A stallReader reads from r, resetting timer to httpTimeout after each successful read.
+type stallReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (s *stallReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	// After an error, leave it to the caller to check whether the timer fired.
+	if err == nil {
+		s.timer.Reset(httpTimeout)
+	}
+	return n, err
+}
+
+// resumes reports whether the 206 response resp continues a file of size bytes
+// of which written bytes have already been received.
+func resumes(resp *http.Response, written, size int64) bool {
+	var start, end, total int64
+	_, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total)
+	return err == nil && start == written && (size < 0 || total == size)
+}
+
+// checkSHA256 returns s, a SHA256 digest given on the command line, in lowercase,
+// or an error if it is not 64 hex digits.
+func checkSHA256(s string) (string, error) {
+	if _, err := hex.DecodeString(s); err != nil || len(s) != 2*sha256.Size {
+		return "", fmt.Errorf("invalid SHA256 %q: want 64 hex digits", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// checksum returns the published SHA256 digest of the archive at url.
+func checksum(ctx context.Context, url string) (string, error) {
+	resp, err := get(ctx, url+".sha256")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	ff := strings.Fields(string(body))
+	if len(ff) == 0 {
+		return "", fmt.Errorf("%s.sha256 is empty", url)
+	}
+	return strings.ToLower(ff[0]), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestParseDlLine(t *testing.T) {
+	const base = "https://storage.googleapis.com/golang/"
+	tests := []struct {
+		line, goos, goarch string
+		want               string // "" if the line is not usable
+	}{
+		{base + "go1.2.2.darwin-amd64-osx10.6.tar.gz", "darwin", "amd64", ""},
+		{base + "go1.2.2.darwin-amd64-osx10.8.tar.gz", "darwin", "amd64", "go1.2.2"},
+		{base + "go1.2.2.linux-amd64.tar.gz", "linux", "amd64", "go1.2.2"},
+		{base + "go1.2.2.linux-amd64.tar.gz", "linux", "386", ""},
+		{base + "go1.6beta1.linux-arm.tar.gz", "linux", "arm", ""},
+		{base + "go1.6beta1.linux-arm6.tar.gz", "linux", "arm", "go1.6beta1"},
+		{base + "go1.16.darwin-arm64.tar.gz", "darwin", "arm64", "go1.16"},
+		{base + "go1.16.darwin-arm64.tar.gz", "darwin", "amd64", ""},
+		{base + "go1.11.linux-ppc64le.tar.gz", "linux", "ppc64le", "go1.11"},
+		{base + "go1.11.linux-ppc64le.tar.gz", "linux", "ppc64", ""},
+		{base + "go1.11.src.tar.gz", "linux", "amd64", ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseDlLine(tt.line, tt.goos, tt.goarch)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("parseDlLine(%q, %s, %s) = %q, %v; want %q, %v", tt.line, tt.goos, tt.goarch, got, ok, tt.want, tt.want != "")
+		}
+	}
+}
+
+func TestParseDlFile(t *testing.T) {
+	const base = "https://dl.google.com/go/"
+	tests := []struct {
+		line string
+		vers string // "" if the file is not a usable archive
+		p    platform
+	}{
+		{base + "go1.21.0.linux-amd64.tar.gz", "go1.21.0", platform{"linux", "amd64"}},
+		{base + "go1.21.0.linux-arm64.tar.gz", "go1.21.0", platform{"linux", "arm64"}},
+		{base + "go1.21.0.linux-armv6l.tar.gz", "go1.21.0", platform{"linux", "arm"}},
+		{base + "go1.21.0.windows-amd64.zip", "go1.21.0", platform{"windows", "amd64"}},
+		{base + "go1.21rc2.darwin-arm64.tar.gz", "go1.21rc2", platform{"darwin", "arm64"}},
+		{"go1.4.3.darwin-amd64-osx10.8.tar.gz", "go1.4.3", platform{"darwin", "amd64"}},
+		{base + "go1.4.3.darwin-amd64-osx10.6.tar.gz", "", platform{}},
+		{base + "go1.21.0.src.tar.gz", "", platform{}},
+		{base + "go1.21.0.windows-amd64.msi", "", platform{}},
+		{base + "go1.21.0.darwin-arm64.pkg", "", platform{}},
+		{base + "go1.21.0.linux-amd64.tar.gz.sha256", "", platform{}},
+		{base + "go1.6beta1.linux-arm.tar.gz", "", platform{}},
+		{"", "", platform{}},
+	}
+	for _, tt := range tests {
+		vers, p, ok := parseDlFile(tt.line)
+		if vers != tt.vers || p != tt.p || ok != (tt.vers != "") {
+			t.Errorf("parseDlFile(%q) = %q, %v, %v; want %q, %v, %v", tt.line, vers, p, ok, tt.vers, tt.p, tt.vers != "")
+		}
+	}
+}
+
+func TestDownloadMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permissions")
+	}
+	const file = "go1.21.0.linux-amd64.tar.gz"
+	archive := []byte("not really a tarball")
+	sum := sha256.Sum256(archive)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/index", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(srv.URL + "/" + file + "\n"))
+	})
+	mux.HandleFunc("/"+file, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/"+file+".sha256", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(hex.EncodeToString(sum[:])))
+	})
+	t.Setenv("GOVERSION_DL_BASE", srv.URL+"/")
+	t.Setenv("GOVERSION_DL_INDEX", srv.URL+"/index")
+	defer func(dir string) { parentDir = dir }(parentDir)
+	parentDir = t.TempDir()
+
+	path, _, err := download(context.Background(), "go1.21.0", platform{"linux", "amd64"}, t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Errorf("downloaded archive has mode %v; want %v", fi.Mode().Perm(), os.FileMode(0644))
+	}
+}
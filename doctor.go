@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// doctor checks the environment for the things that installs need
+// and prints a pass, warn, or fail line for each.
+// It returns an error if any hard requirement is missing.
+func doctor(ctx context.Context) error {
+	failed := false
+	report := func(status, check, detail string) {
+		if status == "fail" {
+			failed = true
+		}
+		fmt.Printf("%-4s  %-12s %s\n", status, check, detail)
+	}
+
+	// Listing and source builds need git.
+	if path, err := exec.LookPath("git"); err != nil {
+		report("fail", "git", "not found in PATH")
+	} else {
+		report("pass", "git", path)
+	}
+
+	// Only source builds need a C compiler, and not with CGO_ENABLED=0.
+	switch path, err := findCC(); {
+	case os.Getenv("CGO_ENABLED") == "0":
+		report("pass", "C compiler", "not needed with CGO_ENABLED=0")
+	case err != nil:
+		report("warn", "C compiler", err.Error()+"; source builds need install -no-cgo")
+	default:
+		report("pass", "C compiler", path)
+	}
+
+	parent := repoParent()
+	if err := checkWritable(parent); err != nil {
+		report("fail", "root", err.Error())
+	} else {
+		report("pass", "root", parent)
+	}
+
+	if *offline {
+		report("warn", "network", "not checked with -offline")
+	} else if index, err := dlIndex(); err != nil {
+		report("fail", "network", err.Error())
+	} else if resp, err := get(ctx, index); err != nil {
+		report("warn", "network", err.Error()+"; only source builds from the mirror will work")
+	} else {
+		resp.Body.Close()
+		report("pass", "network", "reached "+index)
+	}
+
+	vers, err := installed(false)
+	switch {
+	case err != nil:
+		report("fail", "installed", err.Error())
+	case len(vers) == 0:
+		report("pass", "installed", "none")
+	default:
+		report("pass", "installed", strings.Join(vers, " "))
+	}
+
+	// Source builds need a toolchain to bootstrap with;
+	// without one, they install one first.
+	bootstrap := ""
+	for _, v := range vers {
+		pv, err := toolchain.ParseVersion(v)
+		if v == release14 || err == nil && pv.Stable() {
+			bootstrap = v
+		}
+	}
+	if bootstrap == "" {
+		report("warn", "bootstrap", "no release installed; the first source build will install one")
+	} else {
+		report("pass", "bootstrap", bootstrap+" can bootstrap source builds")
+	}
+
+	if failed {
+		return errors.New("doctor found problems")
+	}
+	return nil
+}
+
+// checkWritable reports whether goversion can create files in dir,
+// creating dir if necessary.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".doctor")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printEnv prints shell commands that set GOROOT and PATH to use ref.
+// If unset is set, it instead prints commands that undo them, and ref is ignored.
+// The commands are for PowerShell if powershell is set, and POSIX shells otherwise.
+func printEnv(ref string, powershell, unset bool) error {
+	path := stripPath(os.Getenv("PATH"))
+	var root string
+	if !unset {
+		parent := repoParent()
+		if _, err := goPath(ref); err != nil {
+			return err
+		}
+		root = filepath.Join(parent, ref)
+		path = filepath.Join(root, "bin") + string(filepath.ListSeparator) + path
+	}
+	switch {
+	case powershell && unset:
+		fmt.Println("Remove-Item Env:GOROOT -ErrorAction SilentlyContinue")
+		fmt.Printf("$env:PATH = %s\n", psQuote(path))
+	case powershell:
+		fmt.Printf("$env:GOROOT = %s\n", psQuote(root))
+		fmt.Printf("$env:PATH = %s\n", psQuote(path))
+	case unset:
+		fmt.Println("unset GOROOT")
+		fmt.Printf("export PATH=%s\n", shQuote(path))
+	default:
+		fmt.Printf("export GOROOT=%s\n", shQuote(root))
+		fmt.Printf("export PATH=%s\n", shQuote(path))
+	}
+	return nil
+}
+
+// stripPath removes the bin directories of installed versions from path.
+func stripPath(path string) string {
+	prefix := repoParent() + string(filepath.Separator)
+	var keep []string
+	for _, dir := range filepath.SplitList(path) {
+		if strings.HasPrefix(dir, prefix) && filepath.Base(dir) == "bin" {
+			continue
+		}
+		keep = append(keep, dir)
+	}
+	return strings.Join(keep, string(filepath.ListSeparator))
+}
+
+// shQuote quotes s for POSIX shells.
+func shQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// psQuote quotes s for PowerShell.
+func psQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
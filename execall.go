@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// execAllOptions configures execAll.
+type execAllOptions struct {
+	versions   []string // versions to use; empty means all installed versions
+	stopOnFail bool     // stop after the first version that fails
+}
+
+// execAll runs go with args using each installed version in turn,
+// prefixing each line of output with the version,
+// and then prints a summary of which versions passed.
+func execAll(ctx context.Context, args []string, opt *execAllOptions) error {
+	vers := opt.versions
+	if len(vers) == 0 {
+		var err error
+		if vers, err = installed(false); err != nil {
+			return err
+		}
+		if len(vers) == 0 {
+			return fmt.Errorf("no versions installed")
+		}
+	}
+	parent := repoParent()
+	type result struct {
+		ref string
+		err error
+	}
+	var results []result
+	for _, ref := range vers {
+		path, err := goPath(ref)
+		if err != nil {
+			return err
+		}
+		var mu sync.Mutex
+		cmd := exec.CommandContext(ctx, path, args...)
+		cmd.Env = goEnv(filepath.Join(parent, ref))
+		cmd.Stdin = os.Stdin
+		stdout := &prefixWriter{w: os.Stdout, mu: &mu, prefix: ref + ": "}
+		stderr := &prefixWriter{w: os.Stderr, mu: &mu, prefix: ref + ": "}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = cmd.Run()
+		stdout.flush()
+		stderr.flush()
+		results = append(results, result{ref, err})
+		if err != nil && (opt.stopOnFail || ctx.Err() != nil) {
+			break
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Printf("%s: FAIL (%v)\n", r.ref, r.err)
+		} else {
+			fmt.Printf("%s: ok\n", r.ref)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d versions failed", failed, len(results))
+	}
+	return nil
+}
+
+// A prefixWriter writes each line written to it to w, preceded by prefix.
+// Writers sharing mu don't interleave their lines.
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	buf    []byte // incomplete last line
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// flush writes any incomplete last line.
+func (p *prefixWriter) flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := io.WriteString(p.w, p.prefix)
+	if err == nil {
+		_, err = p.w.Write(line)
+	}
+	return err
+}
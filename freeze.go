@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// originFile is the name of the file in each installed version
+// that records where it came from: "git <commit>" for source builds
+// and "sha256 <digest>" for versions unpacked from an archive.
+const originFile = "origin"
+
+// writeOrigin records in root that the toolchain came from digest of the given kind.
+func writeOrigin(root, kind, digest string) error {
+	if err := os.WriteFile(filepath.Join(root, originFile), []byte(kind+" "+digest+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", originFile, err)
+	}
+	return nil
+}
+
+// readOrigin returns the origin recorded in root, or "unknown"
+// for versions installed before goversion recorded origins.
+func readOrigin(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, originFile))
+	if os.IsNotExist(err) {
+		return "unknown", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// fileSHA256 returns the hex SHA256 digest of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// freeze prints a manifest of the installed versions and their origins,
+// one "<version> <origin>" line per version, sorted by version.
+// Checked in, it lets verify confirm that others run the same toolchains.
+func freeze() error {
+	vers, err := installed(true)
+	if err != nil {
+		return err
+	}
+	parent := repoParent()
+	fmt.Println("# goversion freeze; check with goversion verify <file>")
+	for _, v := range vers {
+		origin, err := readOrigin(filepath.Join(parent, v))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", v, origin)
+	}
+	return nil
+}
+
+// verify checks the installed versions against the manifest written by freeze
+// and reports versions that are missing or came from somewhere else.
+// Versions installed but not in the manifest are reported but are not errors.
+func verify(manifest string) error {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	parent := repoParent()
+	want := map[string]bool{}
+	bad := 0
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		v, origin, ok := strings.Cut(line, " ")
+		if !ok {
+			return fmt.Errorf("%s: malformed line %q", manifest, line)
+		}
+		want[v] = true
+		if _, exist := cmdgo(parent, v); !exist {
+			fmt.Printf("%s: missing\n", v)
+			bad++
+			continue
+		}
+		got, err := readOrigin(filepath.Join(parent, v))
+		if err != nil {
+			return err
+		}
+		if got != origin {
+			fmt.Printf("%s: drift: have %s, want %s\n", v, got, origin)
+			bad++
+			continue
+		}
+		logf("%s: ok", v)
+	}
+	if err := scan.Err(); err != nil {
+		return fmt.Errorf("could not read %s: %v", manifest, err)
+	}
+	vers, err := installed(true)
+	if err != nil {
+		return err
+	}
+	for _, v := range vers {
+		if !want[v] {
+			logf("%s: installed but not in %s", v, manifest)
+		}
+	}
+	if bad > 0 {
+		return errors.New("installed versions do not match " + manifest)
+	}
+	return nil
+}
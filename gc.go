@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// A garbage entry is something in the root that gc would remove.
+type garbage struct {
+	path   string
+	reason string
+}
+
+// findGarbage returns the entries in the root that are clearly left over:
+// directories goversion made that lack a working go command, such as failed builds and exports,
+// partial extractions, stray archives,
+// and the Go 1.4 bootstrap toolchain if nothing installed needs it.
+// The root may be shared, as with the legacy $GOPATH/src/golang.org/x,
+// so anything goversion did not make is left alone.
+func findGarbage(ctx context.Context) ([]garbage, error) {
+	parent := repoParent()
+	fis, err := os.ReadDir(parent)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", parent, err)
+	}
+	def := defaultVersion()
+	var g []garbage
+	for _, fi := range fis {
+		name := fi.Name()
+		path := filepath.Join(parent, name)
+		switch {
+		case name == "go.mirror" || name == def || fi.Type()&os.ModeSymlink != 0:
+			// The mirror, the default version, and the default link itself.
+		case fi.IsDir() && strings.HasPrefix(name, ".download"):
+			g = append(g, garbage{path, "partial download"})
+		case fi.IsDir() && (strings.HasPrefix(name, ".tarball") || strings.HasPrefix(name, ".pkg")):
+			g = append(g, garbage{path, "partial extraction"})
+		case fi.IsDir() && madeByGoversion(parent, name):
+			if _, exist := cmdgo(parent, name); !exist {
+				g = append(g, garbage{path, "no bin/go; failed or interrupted build"})
+			} else if name == release14 && !needRelease14(ctx) {
+				g = append(g, garbage{path, "bootstrap toolchain that no installed version needs"})
+			}
+		case fi.Type().IsRegular() && isArchive(name):
+			g = append(g, garbage{path, "stray archive"})
+		}
+	}
+	return g, nil
+}
+
+// madeByGoversion reports whether the directory name in parent is one that goversion makes:
+// one named for a version, possibly with a platform, GOARM, or GOAMD64 suffix,
+// tip, the bootstrap toolchain, or any directory holding the VERSION or origin file
+// that goversion writes into each toolchain, such as a -ref build.
+func madeByGoversion(parent, name string) bool {
+	ref, _, _ := strings.Cut(name, "-")
+	if _, err := toolchain.ParseVersion(ref); err == nil || name == tipName || name == release14 {
+		return true
+	}
+	for _, file := range []string{"VERSION", originFile} {
+		if fi, err := os.Stat(filepath.Join(parent, name, file)); err == nil && fi.Mode().IsRegular() {
+			return true
+		}
+	}
+	return false
+}
+
+// isArchive reports whether name is that of an official binary archive,
+// such as go1.21.0.linux-amd64.tar.gz, or a partial download of one.
+func isArchive(name string) bool {
+	if strings.HasSuffix(name, ".partial") {
+		// Partial downloads are named like go1.21.0.linux-amd64.tar.gz.123456.partial.
+		name = strings.TrimSuffix(name, ".partial")
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		}
+	}
+	if strings.HasSuffix(name, ".pkg") {
+		name = strings.TrimSuffix(name, ".pkg") + ".tar.gz"
+	}
+	return dlFileRE.MatchString(name)
+}
+
+// needRelease14 reports whether any installed version would need the Go 1.4
+// bootstrap toolchain to be rebuilt from source, as by install -force.
+func needRelease14(ctx context.Context) bool {
+	vers, err := installed(false)
+	if err != nil {
+		return true
+	}
+	for _, name := range vers {
+		// Cross and GOARM or GOAMD64 builds have suffixes, as in go1.18-linux-arm64.
+		ref, _, _ := strings.Cut(name, "-")
+		if _, err := toolchain.ParseVersion(ref); err != nil || builtWithC(ref) {
+			// Branches bootstrap with the latest release, and releases before Go 1.5 need none.
+			continue
+		}
+		if boot, _ := chooseBootstrap(ctx, ref, &installOptions{}); boot == release14 {
+			return true
+		}
+	}
+	return false
+}
+
+// gc removes what findGarbage finds, or with dryrun, reports what it would remove.
+// Unlike prune, it never removes working toolchains other than an unneeded bootstrap.
+func gc(ctx context.Context, dryrun bool) error {
+	g, err := findGarbage(ctx)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range g {
+		size := dirsize(e.path)
+		total += size
+		if dryrun {
+			logEventf(logEvent{Level: "info", Path: e.path}, "would remove %s (%s): %s", e.path, fmtsize(size), e.reason)
+			continue
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			return fmt.Errorf("could not remove %s: %v", e.path, err)
+		}
+		logEventf(logEvent{Level: "info", Path: e.path}, "removed %s (%s): %s", e.path, fmtsize(size), e.reason)
+	}
+	switch {
+	case len(g) == 0:
+		logf("nothing to collect")
+	case dryrun:
+		logf("would free %s", fmtsize(total))
+	default:
+		logf("freed %s", fmtsize(total))
+	}
+	return nil
+}
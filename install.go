@@ -0,0 +1,705 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// installOptions configures install.
+type installOptions struct {
+	binary      bool     // fail rather than build from source
+	source      bool     // do not look for a binary
+	bootstrap   string   // version to bootstrap source builds with; empty means choose automatically
+	target      platform // platform to install for; the zero value means the host
+	force       bool     // reinstall even if already installed
+	keepFailed  bool     // keep the source tree if the build fails
+	name        string   // directory to install into; empty means derived from ref and target
+	goarm       string   // GOARM for source builds, such as 7
+	goamd64     string   // GOAMD64 for source builds, such as v3
+	dryrun      bool     // only print what would be done
+	env         envFlag  // extra environment variables for source builds
+	noCgo       bool     // build with CGO_ENABLED=0
+	as          string   // alias to make for the installed version, if any
+	asJSON      bool     // print an installReport for each version instead of logging
+	keepArchive bool     // keep downloaded archives for debugging
+	sha256      string   // SHA256 the binary archive must have, in lowercase hex; empty means any
+
+	report *installReport // if non-nil, install records what it did here
+}
+
+// An installReport describes the outcome of installing one version, for install -json.
+type installReport struct {
+	Version   string  `json:"version"`             // version or ref requested
+	Path      string  `json:"path,omitempty"`      // GOROOT of the installed version
+	Method    string  `json:"method,omitempty"`    // "binary", "source", or "none" if already installed
+	Bootstrap string  `json:"bootstrap,omitempty"` // GOROOT_BOOTSTRAP of a source build
+	SHA256    string  `json:"sha256,omitempty"`    // digest of the downloaded binary archive
+	Seconds   float64 `json:"seconds"`             // how long the install took
+	OK        bool    `json:"ok"`
+	Error     string  `json:"error,omitempty"`
+
+	// Phases holds the seconds spent in each phase of the install:
+	// download and unpack for binaries; update, bootstrap, export, and build for source builds.
+	Phases map[string]float64 `json:"phases,omitempty"`
+
+	summary []string // the phases for people, in order, such as "built in 1m30s"
+}
+
+// finish records the outcome of an install that began at start and returned err.
+func (r *installReport) finish(start time.Time, err error) {
+	r.Seconds = time.Since(start).Seconds()
+	r.OK = err == nil
+	if err != nil {
+		r.Path = ""
+		r.Error = err.Error()
+	}
+}
+
+// phaseDone describes each install phase once it is over.
+var phaseDone = map[string]string{
+	"download":  "downloaded",
+	"unpack":    "unpacked",
+	"update":    "updated the Go repo",
+	"bootstrap": "bootstrapped",
+	"export":    "exported",
+	"build":     "built",
+}
+
+// timed records that phase took from start until now.
+func (r *installReport) timed(phase string, start time.Time) {
+	d := time.Since(start)
+	if r.Phases == nil {
+		r.Phases = map[string]float64{}
+	}
+	r.Phases[phase] = d.Seconds()
+	if d >= time.Second {
+		d = d.Round(time.Second)
+	} else {
+		d = d.Round(time.Millisecond)
+	}
+	r.summary = append(r.summary, fmt.Sprintf("%s in %v", phaseDone[phase], d))
+	logEventf(logEvent{Level: "debug", Version: r.Version, Phase: phase}, "%s: %s in %v", r.Version, phaseDone[phase], d)
+}
+
+// An envFlag is a repeatable flag of KEY=VALUE environment variables.
+type envFlag []string
+
+func (f *envFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *envFlag) Set(s string) error {
+	if k, _, ok := strings.Cut(s, "="); !ok || k == "" {
+		return fmt.Errorf("%q is not of the form KEY=VALUE", s)
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+// archEnv returns the micro-architecture settings in opt that apply to ref on target,
+// as environment variables for make and as a suffix for the install directory,
+// so that variants such as GOAMD64=v3 don't collide with the default build.
+// Settings that target or ref does not support are ignored with a warning.
+func (opt *installOptions) archEnv(ref string, target platform) (env []string, suffix string) {
+	if opt.goarm != "" {
+		if target.goarch != "arm" {
+			warnf("ignoring -goarm for %s", target)
+		} else {
+			env = append(env, "GOARM="+opt.goarm)
+			suffix += "-goarm" + strings.Replace(opt.goarm, ",", "-", -1)
+		}
+	}
+	if opt.goamd64 != "" {
+		// GOAMD64 first appeared in Go 1.18.
+		v, err := toolchain.ParseVersion(ref)
+		switch {
+		case target.goarch != "amd64":
+			warnf("ignoring -goamd64 for %s", target)
+		case err == nil && v.Less(toolchain.Version{Major: 1, Minor: 18}):
+			warnf("ignoring -goamd64 for %s, which predates GOAMD64", ref)
+		default:
+			env = append(env, "GOAMD64="+opt.goamd64)
+			suffix += "-goamd64" + opt.goamd64
+		}
+	}
+	return env, suffix
+}
+
+// cgoEnv returns the environment that disables cgo for source builds with -no-cgo.
+func (opt *installOptions) cgoEnv() []string {
+	if opt.noCgo {
+		return []string{"CGO_ENABLED=0"}
+	}
+	return nil
+}
+
+// install installs ref.
+// It uses a prebuilt binary when one is available for the current platform
+// and builds from source otherwise.
+func install(ctx context.Context, ref string, opt *installOptions) (err error) {
+	target := opt.target
+	if target == (platform{}) {
+		target = host()
+	}
+	env, suffix := opt.archEnv(ref, target)
+	env = append(env, opt.env...)
+	name := opt.name
+	if name == "" {
+		name = installName(ref, target)
+	}
+	name += suffix
+	if opt.dryrun {
+		return printPlan(ctx, ref, name, target, env, opt)
+	}
+	if opt.as != "" {
+		defer func() {
+			if err == nil {
+				err = alias(ctx, opt.as, name)
+			}
+		}()
+	}
+	rep := opt.report
+	if rep == nil {
+		rep = &installReport{Version: ref}
+	}
+	parent := repoParent()
+	rep.Path = filepath.Join(parent, name)
+	if _, exist := cmdgo(parent, name); exist {
+		if !opt.force {
+			rep.Method = "none"
+			if opt.sha256 != "" {
+				// Hold what is already installed to the pin too.
+				if origin, _ := readOrigin(rep.Path); origin != "sha256 "+opt.sha256 {
+					return fmt.Errorf("%s is already installed, but from %s, not the pinned archive; use -force to reinstall", name, origin)
+				}
+			}
+			logf("%s is already installed", name)
+			return nil
+		}
+		root := filepath.Join(parent, name)
+		vlogf("removing %s", root)
+		if err := os.RemoveAll(root); err != nil {
+			return fmt.Errorf("could not remove %s: %v", root, err)
+		}
+	}
+	// Offline, the Go mirror is the only source of toolchains.
+	if *offline && opt.binary {
+		return fmt.Errorf("could not install %s: cannot download binaries with -offline", ref)
+	}
+	// Binary releases are built with the default micro-architecture and settings.
+	if len(env) > 0 && opt.binary {
+		return fmt.Errorf("could not install %s: no binaries for -env, -goarm, or -goamd64 builds", ref)
+	}
+	if !opt.source && !*offline && len(env) == 0 {
+		if err := checkSpace(binaryNeed); err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp(repoParent(), ".download")
+		if err != nil {
+			return err
+		}
+		if opt.keepArchive {
+			// Remove dir only if nothing was downloaded into it.
+			defer os.Remove(dir)
+		} else {
+			defer os.RemoveAll(dir)
+		}
+		start := time.Now()
+		path, sum, err := download(ctx, ref, target, dir, opt.sha256)
+		if err == nil {
+			rep.timed("download", start)
+			rep.SHA256 = sum
+			if opt.keepArchive {
+				logEventf(logEvent{Level: "info", Version: ref, Path: path}, "keeping %s", path)
+			}
+			rep.Method = "binary"
+			start = time.Now()
+			if err := unpack(ref, name, path); err != nil {
+				return err
+			}
+			// The go command of a cross toolchain may not run here.
+			if target == host() {
+				if err := verifyInstall(rep.Path); err != nil {
+					os.RemoveAll(rep.Path)
+					return err
+				}
+			}
+			rep.timed("unpack", start)
+			logEventf(logEvent{Level: "info", Version: ref}, "%s: %s", ref, strings.Join(rep.summary, ", "))
+			return nil
+		}
+		if err != errNoBinary {
+			return fmt.Errorf("could not download %s: %v", ref, err)
+		}
+		if opt.binary {
+			return fmt.Errorf("could not install %s: no binary for %s", ref, target)
+		}
+		logEventf(logEvent{Level: "info", Version: ref}, "no binary for %s, building from source", ref)
+	}
+
+	rep.Method = "source"
+	if err := checkBuildable(ref, target); err != nil {
+		return err
+	}
+	// Only source builds need the Go repo.
+	start := time.Now()
+	if !updated {
+		if err := update(ctx); err != nil {
+			return err
+		}
+		rep.timed("update", start)
+	}
+	if builtWithC(ref) {
+		if opt.bootstrap != "" {
+			warnf("%s is written in C and needs no bootstrap; ignoring -bootstrap", ref)
+		}
+		os.Unsetenv("GOROOT_BOOTSTRAP")
+	} else {
+		start = time.Now()
+		bootstrap, err := bootstrapFor(ctx, ref, opt)
+		if err != nil {
+			return err
+		}
+		rep.timed("bootstrap", start)
+		rep.Bootstrap = bootstrap
+		vlogf("using GOROOT_BOOTSTRAP=%s", bootstrap)
+		os.Setenv("GOROOT_BOOTSTRAP", bootstrap)
+	}
+
+	if err := checkSpace(sourceNeed); err != nil {
+		return err
+	}
+	start = time.Now()
+	if err := export(ctx, ref, name); err != nil {
+		return err
+	}
+	rep.timed("export", start)
+	start = time.Now()
+	// Binaries don't need a C compiler, so -no-cgo affects only source builds.
+	if err := make(ctx, name, target, append(env, opt.cgoEnv()...), opt.keepFailed); err != nil {
+		return err
+	}
+	rep.timed("build", start)
+	logEventf(logEvent{Level: "info", Version: ref}, "%s: %s", ref, strings.Join(rep.summary, ", "))
+	return nil
+}
+
+var (
+	autoInstall = flag.Bool("auto-install", false, "install missing versions before running them (or set GOVERSION_AUTO_INSTALL=1)")
+	assumeYes   = flag.Bool("y", false, "install missing versions, or pick matching releases, without asking")
+)
+
+// ensureInstalled returns the path of the go command of ref.
+// If ref is not installed and -auto-install or GOVERSION_AUTO_INSTALL=1 is set,
+// it installs ref first, asking for confirmation on a terminal unless -y is set.
+// Otherwise, on a terminal, it offers installed versions close to ref instead.
+func ensureInstalled(ctx context.Context, ref string) (string, error) {
+	path, err := goPath(ref)
+	var nie *toolchain.NotInstalledError
+	if !errors.As(err, &nie) || ref == tipName {
+		return path, err
+	}
+	if !*autoInstall && os.Getenv("GOVERSION_AUTO_INSTALL") != "1" {
+		alt, perr := chooseInstalled(ref)
+		if perr != nil {
+			return "", perr
+		}
+		if alt != "" {
+			return goPath(alt)
+		}
+		return path, err
+	}
+	if !*assumeYes && interactive() {
+		fmt.Fprintf(os.Stderr, "%s is not installed. Install it now? [Y/n] ", ref)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "" && a != "y" && a != "yes" {
+			return "", err
+		}
+	}
+	unlock, err := lock()
+	if err != nil {
+		return "", err
+	}
+	// Unlock explicitly: the caller goes on to run go, which exits without running deferred calls.
+	err = install(ctx, ref, &installOptions{})
+	unlock()
+	if err != nil {
+		return "", err
+	}
+	return goPath(ref)
+}
+
+// printPlan prints how install would install ref into the directory name,
+// without changing anything.
+func printPlan(ctx context.Context, ref, name string, target platform, env []string, opt *installOptions) error {
+	parent := repoParent()
+	root := filepath.Join(parent, name)
+	fmt.Printf("%s:\n", ref)
+	if _, exist := cmdgo(parent, name); exist {
+		if !opt.force {
+			fmt.Printf("\talready installed in %s\n", root)
+			return nil
+		}
+		fmt.Printf("\tremove %s\n", root)
+	}
+	if *offline && opt.binary {
+		return fmt.Errorf("could not install %s: cannot download binaries with -offline", ref)
+	}
+	if len(env) > 0 && opt.binary {
+		return fmt.Errorf("could not install %s: no binaries for -env, -goarm, or -goamd64 builds", ref)
+	}
+	if !opt.source && !*offline && len(env) == 0 {
+		url, err := selectBinary(ctx, ref, target)
+		if err == nil {
+			fmt.Printf("\tdownload %s\n", url)
+			if opt.sha256 != "" {
+				fmt.Printf("\tcheck that it has sha256 %s\n", opt.sha256)
+			}
+			fmt.Printf("\tunpack into %s (needs about %s free)\n", root, fmtsize(binaryNeed))
+			return nil
+		}
+		if err != errNoBinary {
+			return err
+		}
+		if opt.binary {
+			return fmt.Errorf("could not install %s: no binary for %s", ref, target)
+		}
+		fmt.Printf("\tno binary for %s\n", target)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "go.mirror")); err != nil {
+		remote, err := gitRemote()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\tclone %s\n", remote)
+	}
+	if err := checkBuildable(ref, target); err != nil {
+		return err
+	}
+	if builtWithC(ref) {
+		fmt.Printf("\tbuild from source into %s with the C compiler", root)
+	} else {
+		bootstrap, err := chooseBootstrap(ctx, ref, opt)
+		if err != nil {
+			return err
+		}
+		bootroot := filepath.Join(parent, bootstrap)
+		if _, exist := cmdgo(parent, bootstrap); !exist {
+			if sysroot, vers, ok := systemBootstrap(ctx, ref); ok && opt.bootstrap == "" {
+				fmt.Printf("\tbootstrap with %s from PATH\n", vers)
+				bootroot = sysroot
+			} else {
+				fmt.Printf("\tinstall %s to bootstrap with\n", bootstrap)
+			}
+		}
+		fmt.Printf("\tbuild from source into %s with GOROOT_BOOTSTRAP=%s", root, bootroot)
+	}
+	if target != host() {
+		fmt.Printf(" GOOS=%s GOARCH=%s", target.goos, target.goarch)
+	}
+	for _, kv := range env {
+		fmt.Printf(" %s", kv)
+	}
+	fmt.Printf("\n\t(needs about %s free; builds take several minutes)\n", fmtsize(sourceNeed))
+	return nil
+}
+
+// installAll installs each of refs in turn.
+// A failure doesn't stop the rest from being installed,
+// but installAll returns an error naming the versions that failed.
+// Versions are installed one at a time so that later versions
+// can reuse a bootstrap toolchain installed for an earlier one.
+func installAll(ctx context.Context, refs []string, opt *installOptions) error {
+	if opt.asJSON {
+		return installJSON(ctx, refs, opt)
+	}
+	if len(refs) == 1 || opt.dryrun {
+		for _, ref := range refs {
+			if err := install(ctx, ref, opt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var failed []string
+	for i, ref := range refs {
+		if ctx.Err() != nil {
+			failed = append(failed, refs[i:]...)
+			break
+		}
+		o := *opt
+		if err := install(ctx, ref, &o); err != nil {
+			logEventf(logEvent{Level: "error", Version: ref}, "%s: %v", ref, err)
+			failed = append(failed, ref)
+			continue
+		}
+		logEventf(logEvent{Level: "info", Version: ref}, "%s: ok", ref)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not install %d of %d versions: %s", len(failed), len(refs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// installJSON installs each of refs in turn like installAll,
+// printing an installReport as a JSON object on its own line for each.
+func installJSON(ctx context.Context, refs []string, opt *installOptions) error {
+	enc := json.NewEncoder(os.Stdout)
+	var failed []string
+	for _, ref := range refs {
+		rep := &installReport{Version: ref}
+		o := *opt
+		o.report = rep
+		start := time.Now()
+		err := ctx.Err()
+		if err == nil {
+			err = install(ctx, ref, &o)
+		}
+		rep.finish(start, err)
+		if err != nil {
+			failed = append(failed, ref)
+		}
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not install %d of %d versions: %s", len(failed), len(refs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// refName returns the directory name to install the git ref ref under.
+// Characters that are unsafe in file names, such as path separators, become dashes.
+// Names that would collide with goversion's own files are rejected.
+func refName(ref string) (string, error) {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, ref)
+	if name == "" || strings.HasPrefix(name, ".") || name == "go.mirror" || name == current || name == aliasFile {
+		return "", fmt.Errorf("cannot install ref %q: invalid directory name %q", ref, name)
+	}
+	return name, nil
+}
+
+// bootstrapFor returns the GOROOT of a toolchain that can bootstrap ref,
+// installing the one chooseBootstrap picks first if necessary.
+func bootstrapFor(ctx context.Context, ref string, opt *installOptions) (string, error) {
+	parent := repoParent()
+	want, err := chooseBootstrap(ctx, ref, opt)
+	if err != nil {
+		return "", err
+	}
+	if _, exist := cmdgo(parent, want); !exist && opt.bootstrap == "" {
+		if root, vers, ok := systemBootstrap(ctx, ref); ok {
+			logEventf(logEvent{Level: "info", Version: ref, Phase: "bootstrap", Path: root}, "using %s from PATH to bootstrap %s", vers, ref)
+			return root, nil
+		}
+	}
+	if _, exist := cmdgo(parent, want); !exist {
+		logEventf(logEvent{Level: "info", Version: ref, Phase: "bootstrap"}, "installing %s to bootstrap %s", want, ref)
+		if want == release14 {
+			// Go 1.4 is the last release written in C; it needs no bootstrap.
+			if err = export(ctx, release14, release14); err == nil {
+				err = make(ctx, release14, host(), opt.cgoEnv(), opt.keepFailed)
+			}
+		} else {
+			err = install(ctx, want, &installOptions{keepFailed: opt.keepFailed, noCgo: opt.noCgo})
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(parent, want), nil
+}
+
+// chooseBootstrap returns the version to bootstrap ref with.
+// If opt.bootstrap is non-empty, that version is used.
+// Otherwise chooseBootstrap prefers the newest installed release that can bootstrap ref,
+// and picks the oldest acceptable release if there is none.
+func chooseBootstrap(ctx context.Context, ref string, opt *installOptions) (string, error) {
+	if opt.bootstrap != "" {
+		return opt.bootstrap, nil
+	}
+	min, err := minBootstrap(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	var best string
+	var bestv toolchain.Version
+	vers, err := installed(false)
+	if err != nil {
+		return "", err
+	}
+	for _, inst := range vers {
+		if !canBootstrap(inst, min) {
+			continue
+		}
+		if v, _ := toolchain.ParseVersion(inst); best == "" || bestv.Less(v) {
+			best, bestv = inst, v
+		}
+	}
+	if best == "" {
+		return min, nil
+	}
+	return best, nil
+}
+
+// canBootstrap reports whether the toolchain of version vers can bootstrap
+// builds whose oldest acceptable bootstrap is min, as returned by minBootstrap.
+// Any stable release from Go 1.4 on can stand in for the Go 1.4 bootstrap toolchain.
+func canBootstrap(vers, min string) bool {
+	if min == release14 {
+		min = "go1.4"
+	}
+	minv, err := toolchain.ParseVersion(min)
+	if err != nil {
+		return false
+	}
+	v, err := toolchain.ParseVersion(vers)
+	return err == nil && v.Stable() && !v.Less(minv)
+}
+
+// systemBootstrap looks for a go command on PATH that can bootstrap ref,
+// and returns its GOROOT and version.
+func systemBootstrap(ctx context.Context, ref string) (root, vers string, ok bool) {
+	path, err := exec.LookPath("go")
+	if err != nil {
+		return "", "", false
+	}
+	min, err := minBootstrap(ctx, ref)
+	if err != nil {
+		return "", "", false
+	}
+	// The output is like "go version go1.21.0 linux/amd64".
+	out, err := probe(ctx, path, "version")
+	if err != nil {
+		return "", "", false
+	}
+	f := strings.Fields(string(out))
+	if len(f) < 3 {
+		return "", "", false
+	}
+	if !canBootstrap(f[2], min) {
+		vlogf("%s is %s, which cannot bootstrap %s", path, strings.TrimSpace(string(out)), ref)
+		return "", "", false
+	}
+	out, err = probe(ctx, path, "env", "GOROOT")
+	if err != nil {
+		return "", "", false
+	}
+	return strings.TrimSpace(string(out)), f[2], true
+}
+
+// probe runs the go command at path with args and returns its output.
+// As in verifyInstall, it runs outside any module with GOTOOLCHAIN=local,
+// so that the go command reports on itself rather than switching toolchains.
+// The inherited GOROOT is dropped, so that it reports its own.
+func probe(ctx context.Context, path string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = filepath.Dir(path)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOROOT=") && !strings.HasPrefix(kv, "GOTOOLCHAIN=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, "GOTOOLCHAIN=local")
+	return cmd.Output()
+}
+
+// minBootstrap returns the oldest release that can bootstrap ref.
+// Unrecognized refs, such as development branches, use the latest release.
+func minBootstrap(ctx context.Context, ref string) (string, error) {
+	v, err := toolchain.ParseVersion(ref)
+	switch {
+	case err != nil:
+		return latest(ctx)
+	case v.Minor < 20:
+		return release14, nil
+	case v.Minor < 22:
+		return "go1.17.13", nil
+	case v.Minor < 24:
+		return "go1.20.6", nil
+	}
+	// Starting with Go 1.24, Go 1.N requires Go 1.(N-2).6 or later,
+	// with N rounded down to an even number.
+	return "go1." + strconv.Itoa(v.Minor-v.Minor%2-2) + ".6", nil
+}
+
+// firstGoBuilt is the first release written in Go.
+// Earlier releases, back to go1, build their compilers with the host C compiler,
+// so they need a C compiler but no bootstrap toolchain.
+var firstGoBuilt = toolchain.Version{Major: 1, Minor: 5}
+
+// builtWithC reports whether ref's toolchain is written in C.
+func builtWithC(ref string) bool {
+	if ref == release14 {
+		return true
+	}
+	v, err := toolchain.ParseVersion(ref)
+	return err == nil && v.Less(firstGoBuilt)
+}
+
+// portsSince records the first release to support ports added after go1.
+// Older releases cannot be built for them.
+var portsSince = map[platform]string{
+	{"darwin", "arm64"}:   "go1.16",
+	{"freebsd", "arm64"}:  "go1.14",
+	{"linux", "arm64"}:    "go1.5",
+	{"linux", "loong64"}:  "go1.19",
+	{"linux", "mips"}:     "go1.8",
+	{"linux", "mipsle"}:   "go1.8",
+	{"linux", "mips64"}:   "go1.6",
+	{"linux", "mips64le"}: "go1.6",
+	{"linux", "ppc64"}:    "go1.5",
+	{"linux", "ppc64le"}:  "go1.5",
+	{"linux", "riscv64"}:  "go1.14",
+	{"linux", "s390x"}:    "go1.7",
+	{"netbsd", "arm64"}:   "go1.13",
+	{"openbsd", "arm64"}:  "go1.13",
+	{"windows", "arm"}:    "go1.12",
+	{"windows", "arm64"}:  "go1.17",
+}
+
+// checkBuildable reports an error if the source of ref is known not to build for target.
+// Releases written in C also build their compilers for the host, so the host must be supported too.
+func checkBuildable(ref string, target platform) error {
+	v, err := toolchain.ParseVersion(ref)
+	if ref == release14 {
+		v, err = toolchain.ParseVersion("go1.4")
+	}
+	if err != nil {
+		// Branches and commits are too varied to check.
+		return nil
+	}
+	ps := []platform{target}
+	if builtWithC(ref) && target != host() {
+		ps = append(ps, host())
+	}
+	for _, p := range ps {
+		since, ok := portsSince[p]
+		if !ok {
+			continue
+		}
+		if sv, _ := toolchain.ParseVersion(since); v.Less(sv) {
+			return fmt.Errorf("cannot build %s for %s: %s predates the %s port, added in %s", ref, target, ref, p, since)
+		}
+	}
+	return nil
+}
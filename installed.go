@@ -0,0 +1,38 @@
+package main
+
+import "github.com/josharian/goversion/toolchain"
+
+// manager returns the toolchain.Manager for repoParent.
+func manager() *toolchain.Manager {
+	return &toolchain.Manager{Root: repoParent()}
+}
+
+// installed returns the installed versions, sorted oldest first.
+// The bootstrap toolchain is included only if all is set.
+func installed(all bool) ([]string, error) {
+	vers, err := manager().Installed()
+	if err != nil || all {
+		return vers, err
+	}
+	out := vers[:0]
+	for _, v := range vers {
+		if v != release14 {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// installArgs returns the goversion arguments that install ref.
+func installArgs(ref string) string {
+	if ref == tipName {
+		return "tip"
+	}
+	return "install " + ref
+}
+
+// goPath returns the path of the go command of the installed version ref.
+// If ref is not installed, the error is a *toolchain.NotInstalledError.
+func goPath(ref string) (string, error) {
+	return manager().Which(ref)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var lockTimeout = flag.Duration("lock-timeout", 0, "wait up to this long for another goversion to finish")
+
+// lock acquires the lock that protects repoParent from concurrent modification
+// and returns a function that releases it.
+// The lock is held by an open file, so the operating system releases it
+// if goversion exits without unlocking.
+func lock() (unlock func(), err error) {
+	parent := repoParent()
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, fmt.Errorf("could not create %s: %v", parent, err)
+	}
+	path := filepath.Join(parent, ".lock")
+	deadline := time.Now().Add(*lockTimeout)
+	for {
+		unlock, err := tryLock(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if err != errLocked {
+			return nil, fmt.Errorf("could not lock %s: %v", path, err)
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("another goversion is running (lock %s is held); use -lock-timeout to wait for it", path)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
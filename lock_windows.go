@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errLocked = errors.New("locked")
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION, which package syscall does not define.
+const errorSharingViolation syscall.Errno = 32
+
+// tryLock opens path for exclusive access without blocking.
+// It returns errLocked if another process has it open.
+func tryLock(path string) (unlock func(), err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	// A share mode of 0 denies all other opens until the handle is closed.
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if err == errorSharingViolation {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return func() { syscall.CloseHandle(h) }, nil
+}
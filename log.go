@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	verbose = flag.Bool("v", false, "print more output")
+	quiet   = flag.Bool("q", false, "print only errors")
+	logJSON = flag.Bool("log-json", false, "print log messages as JSON objects")
+)
+
+// A logEvent is a log message, printed as a JSON object with -log-json.
+type logEvent struct {
+	Time    string `json:"time"`              // RFC 3339, UTC
+	Level   string `json:"level"`             // "debug", "info", "warning", or "error"
+	Msg     string `json:"msg"`               // the message, as printed without -log-json
+	Version string `json:"version,omitempty"` // version the message is about
+	Phase   string `json:"phase,omitempty"`   // install phase, such as download or build
+	Path    string `json:"path,omitempty"`    // file, directory, or URL the message is about
+}
+
+var logMu sync.Mutex // serializes -log-json output
+
+// logEventf logs the message format, args with the level and fields of ev.
+// Debug messages need -v; -q suppresses all but warnings and errors.
+func logEventf(ev logEvent, format string, args ...interface{}) {
+	switch ev.Level {
+	case "debug":
+		if !*verbose || *quiet {
+			return
+		}
+	case "info":
+		if *quiet {
+			return
+		}
+	}
+	ev.Msg = fmt.Sprintf(format, args...)
+	if !*logJSON {
+		log.Print(ev.Msg)
+		return
+	}
+	ev.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	logMu.Lock()
+	defer logMu.Unlock()
+	json.NewEncoder(os.Stderr).Encode(ev)
+}
+
+// logf logs a progress message, unless -q is set.
+func logf(format string, args ...interface{}) {
+	logEventf(logEvent{Level: "info"}, format, args...)
+}
+
+// vlogf logs a detailed message, if -v is set.
+func vlogf(format string, args ...interface{}) {
+	logEventf(logEvent{Level: "debug"}, format, args...)
+}
+
+// warnf logs a warning, even with -q.
+func warnf(format string, args ...interface{}) {
+	logEventf(logEvent{Level: "warning"}, "warning: "+format, args...)
+}
+
+// errorf logs an error, even with -q.
+func errorf(format string, args ...interface{}) {
+	logEventf(logEvent{Level: "error"}, format, args...)
+}
+
+// fatalf logs an error and exits with status 1.
+func fatalf(format string, args ...interface{}) {
+	errorf(format, args...)
+	os.Exit(1)
+}
@@ -3,271 +3,662 @@
 package main
 
 import (
-	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
-	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+
+	"github.com/josharian/goversion/toolchain"
 )
 
 const (
-	remote    = "https://go.googlesource.com/go"
+	remote    = toolchain.DefaultRemote
 	release14 = "release-branch.go1.4"
-	debug     = false
 )
 
-// list prints the available tagged releases.
-func list() {
-	cmd := exec.Command("git", "ls-remote", "--tags", remote, "go1*")
+// listOptions configures list.
+type listOptions struct {
+	desc          bool              // print the newest release first
+	asJSON        bool              // print JSON
+	mark          bool              // mark installed releases with a trailing *
+	installedOnly bool              // print only installed releases
+	stableOnly    bool              // omit betas and release candidates
+	min, max      toolchain.Version // print only releases in this range, inclusive
+	hasMin        bool              // whether min is set
+	hasMax        bool              // whether max is set
+	binary        bool              // list versions with binary downloads instead of tags
+	all           bool              // list both, noting where each is available
+	platform      platform          // platform of binary downloads; the zero value means the host
+	limit         int               // print only the newest this many versions; 0 means all
+}
+
+// list prints the available versions in version order:
+// the tagged releases, which can be built from source,
+// the versions with binary downloads, or both.
+func list(ctx context.Context, opt *listOptions) error {
+	var vers []string
+	avail := map[string][]string{} // version -> "source" and/or "binary"
+	add := func(vs []string, kind string) {
+		for _, v := range vs {
+			if avail[v] == nil {
+				vers = append(vers, v)
+			}
+			avail[v] = append(avail[v], kind)
+		}
+	}
+	if !opt.binary {
+		src, err := tags(ctx)
+		if err != nil {
+			return err
+		}
+		add(src, "source")
+	}
+	if opt.binary || opt.all {
+		p := opt.platform
+		if p == (platform{}) {
+			p = host()
+		}
+		bin, err := dlversions(ctx, p)
+		if err != nil {
+			return err
+		}
+		add(bin, "binary")
+	}
+	sort.SliceStable(vers, func(i, j int) bool {
+		if opt.desc {
+			i, j = j, i
+		}
+		return toolchain.TagLess(vers[i], vers[j])
+	})
+	parent := repoParent()
+	var keep []string
+	for _, tag := range vers {
+		if opt.installedOnly {
+			if _, exist := cmdgo(parent, tag); !exist {
+				continue
+			}
+		}
+		if opt.stableOnly || opt.hasMin || opt.hasMax {
+			v, err := toolchain.ParseVersion(tag)
+			if err != nil ||
+				opt.stableOnly && !v.Stable() ||
+				opt.hasMin && v.Less(opt.min) ||
+				opt.hasMax && opt.max.Less(v) {
+				continue
+			}
+		}
+		keep = append(keep, tag)
+	}
+	vers = keep
+	if opt.limit > 0 && len(vers) > opt.limit {
+		if opt.desc {
+			vers = vers[:opt.limit]
+		} else {
+			vers = vers[len(vers)-opt.limit:]
+		}
+	}
+	if opt.asJSON {
+		infos := versionInfos(vers)
+		if opt.all {
+			for i := range infos {
+				infos[i].Available = avail[infos[i].Version]
+			}
+		}
+		return printJSON(infos)
+	}
+	for _, tag := range vers {
+		line := tag
+		if opt.all {
+			line += " (" + strings.Join(avail[tag], ", ") + ")"
+		}
+		if _, exist := cmdgo(parent, tag); exist && opt.mark {
+			line += " *"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// A versionInfo describes a Go version in -json output.
+type versionInfo struct {
+	Version   string `json:"version"`   // version name, such as go1.8 or go1.8beta1
+	Stable    bool   `json:"stable"`    // version is a final release, not a beta or release candidate
+	Installed bool   `json:"installed"` // version is installed locally
+
+	// Available is how the version can be installed, "source" and/or "binary".
+	// Only list -all sets it.
+	Available []string `json:"available,omitempty"`
+}
+
+// printVersions prints vers, one per line or as a JSON array of versionInfo.
+func printVersions(vers []string, asJSON bool) error {
+	if !asJSON {
+		for _, v := range vers {
+			fmt.Println(v)
+		}
+		return nil
+	}
+	return printJSON(versionInfos(vers))
+}
+
+// versionInfos describes vers for -json output.
+func versionInfos(vers []string) []versionInfo {
+	parent := repoParent()
+	infos := []versionInfo{}
+	for _, v := range vers {
+		gv, err := toolchain.ParseVersion(v)
+		_, exist := cmdgo(parent, v)
+		infos = append(infos, versionInfo{Version: v, Stable: err == nil && gv.Stable(), Installed: exist})
+	}
+	return infos
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+// tags returns the tagged releases in the Go repo.
+// With -offline, they come from the mirror instead.
+func tags(ctx context.Context) ([]string, error) {
+	if err := needGit(); err != nil {
+		return nil, err
+	}
+	if !*offline {
+		remote, err := gitRemote()
+		if err != nil {
+			return nil, err
+		}
+		m := manager()
+		m.Remote = remote
+		return m.List(ctx)
+	}
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objectname) %(refname)", "refs/tags/go1*")
+	cmd.Dir = filepath.Join(repoParent(), "go.mirror")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("could not list tags: %v\n\n%s", err, out)
 	}
+	var tags []string
 	scan := bufio.NewScanner(bytes.NewReader(out))
 	for scan.Scan() {
 		line := scan.Text()
 		ff := strings.Fields(line)
 		if len(ff) != 2 {
-			log.Fatalf("unexpected git ls-remote line %q", line)
+			return nil, fmt.Errorf("unexpected git for-each-ref line %q", line)
 		}
-		fmt.Println(strings.TrimPrefix(ff[1], "refs/tags/"))
+		tags = append(tags, strings.TrimPrefix(ff[1], "refs/tags/"))
+	}
+	return tags, nil
+}
+
+// releases returns the tagged releases.
+// Binary installs don't otherwise need git, so without it,
+// releases lists the versions in the download index instead.
+func releases(ctx context.Context) ([]string, error) {
+	tags, err := tags(ctx)
+	if err != nil && needGit() != nil && !*offline {
+		tags, err = dlversions(ctx, host())
 	}
+	return tags, err
 }
 
-func listdl() {
-	resp, err := http.Get("https://storage.googleapis.com/go-builder-data/dl-index.txt")
+// latest returns the newest stable tagged release.
+// Without git, it returns the newest release with a binary for the host instead.
+func latest(ctx context.Context) (string, error) {
+	tags, err := releases(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	defer resp.Body.Close()
-	scan := bufio.NewScanner(resp.Body)
-	nosuffix := strings.NewReplacer(".tar.gz", "", ".zip", "")
-	targetos := runtime.GOOS
-	targetarch := runtime.GOARCH
-	for scan.Scan() {
-		// Example line:
-		// https://storage.googleapis.com/golang/go1.2.2.darwin-386-osx10.6.tar.gz
-		line := scan.Text()
-		// Ignore downloads that we can't use directly.
-		if strings.HasSuffix(line, ".pkg") ||
-			strings.HasSuffix(line, ".msi") ||
-			strings.HasSuffix(line, ".sha256") ||
-			strings.HasSuffix(line, ".src.tar.gz") ||
-			!strings.Contains(line, targetos) {
+	var best string
+	var bestv toolchain.Version
+	for _, tag := range tags {
+		v, err := toolchain.ParseVersion(tag)
+		if err != nil || !v.Stable() {
 			continue
 		}
-		// Strip down to just the filename.
-		// go1.2.2.darwin-386-osx10.6.tar.gz
-		i := strings.LastIndexByte(line, '/')
-		if i == -1 {
-			continue
+		if best == "" || bestv.Less(v) {
+			best, bestv = tag, v
 		}
-		line = line[i+1:]
-		// Eliminate file suffixes.
-		// go1.2.2.darwin-386-osx10.6
-		line = nosuffix.Replace(line)
-		// Break up remainder into version and platform.
-		// The pattern is version.platform, but platform can contain periods.
-		// Instead, split on GOOS.
-		// go1.2.2 and darwin-386-osx10.6
-		i = strings.Index(line, targetos)
-		vers, plat := line[:i-1], line[i:]
-		// Platform can contain two or three components.
-		// If two, GOOS and GOARCH.
-		// If three, GOOS, GOARCH, sub-GOARCH.
-		// We know GOOS matches.
-		// GOARCH and sub-GOARCH have a lot of variation.
-		platx := strings.Split(plat, "-")
-		switch len(platx) {
-		default:
-			continue // Not the droid we're looking for.
-		case 3:
-			// Only happens with darwin.
-			// Assume no-one runs 10.6 anymore.
-			if platx[2] == "osx10.6" {
-				continue
-			}
-			platx = platx[:2]
-		case 2:
-			// Continued below.
-		}
-		arch := platx[1]
-		// Clean up arch.
-		// go1.6beta1 has linux-arm and linux-arm6 downloads.
-		// Every other release has armv6l.
-		// Skip plain arm and then map arm6 and armv6l to arm, to match GOARCH naming.
-		switch arch {
-		case "arm":
+	}
+	if best == "" {
+		return "", errors.New("could not find any stable releases")
+	}
+	return best, nil
+}
+
+// listdl prints the versions with binary downloads for p.
+func listdl(ctx context.Context, p platform, asJSON bool) error {
+	vers, err := dlversions(ctx, p)
+	if err != nil {
+		return err
+	}
+	return printVersions(vers, asJSON)
+}
+
+// listdlAll prints every version in the download index
+// with the platforms that it has binary downloads for.
+func listdlAll(ctx context.Context, asJSON bool) error {
+	urls, err := getdlindex(ctx)
+	if err != nil {
+		return err
+	}
+	platforms := map[string][]string{}
+	for _, url := range urls {
+		v, p, ok := parseDlFile(url)
+		if !ok {
 			continue
-		case "arm6", "armv6l":
-			arch = "arm"
 		}
-		if arch != targetarch {
+		platforms[v] = append(platforms[v], p.String())
+	}
+	type dlInfo struct {
+		Version   string   `json:"version"`
+		Platforms []string `json:"platforms"` // GOOS/GOARCH pairs
+	}
+	infos := []dlInfo{}
+	for v, ps := range platforms {
+		sort.Strings(ps)
+		infos = append(infos, dlInfo{v, ps})
+	}
+	sort.Slice(infos, func(i, j int) bool { return toolchain.TagLess(infos[i].Version, infos[j].Version) })
+	if asJSON {
+		return printJSON(infos)
+	}
+	for _, info := range infos {
+		fmt.Printf("%-12s %s\n", info.Version, strings.Join(info.Platforms, " "))
+	}
+	return nil
+}
+
+// dlversions returns the versions with binary downloads for p.
+func dlversions(ctx context.Context, p platform) ([]string, error) {
+	r, err := openDlIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return scanDlVersions(r, p)
+}
+
+// scanDlVersions returns the versions that the download index r lists binary downloads of for p.
+func scanDlVersions(r io.Reader, p platform) ([]string, error) {
+	scan := bufio.NewScanner(r)
+	var vers []string
+	for scan.Scan() {
+		v, ok := parseDlLine(scan.Text(), p.goos, p.goarch)
+		if !ok {
 			continue
 		}
-		fmt.Println(vers)
+		vers = append(vers, v)
 	}
-	if scan.Err() != nil {
-		log.Fatal(err)
+	if err := scan.Err(); err != nil {
+		return nil, fmt.Errorf("could not read download index: %v", err)
 	}
+	return vers, nil
 }
 
+// parentDir is the parent directory of the Go repo(s).
+// main sets it using findRepoParent.
+var parentDir string
+
 // repoParent returns the parent directory of the Go repo(s).
 func repoParent() string {
+	return parentDir
+}
+
+// findRepoParent determines the parent directory of the Go repo(s).
+// It is $GOVERSION_ROOT if set, and then the root setting in the config file.
+// Otherwise, for compatibility with older versions of goversion,
+// it is $GOPATH/src/golang.org/x if that holds a Go mirror,
+// and $HOME/.goversion if not.
+func findRepoParent() (string, error) {
+	if root, source := setting("GOVERSION_ROOT"); root != "" {
+		if source != "GOVERSION_ROOT" && !filepath.IsAbs(root) {
+			// A relative root would depend on the working directory.
+			return "", fmt.Errorf("invalid %s %q: want an absolute path", source, root)
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return "", fmt.Errorf("could not determine repo path: %v", err)
+		}
+		return abs, nil
+	}
+	if legacy := gopathParent(); legacy != "" {
+		if _, err := os.Stat(filepath.Join(legacy, "go.mirror")); err == nil {
+			return legacy, nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine repo path: %v; set GOVERSION_ROOT", err)
+	}
+	return filepath.Join(home, ".goversion"), nil
+}
+
+// gopathParent returns the directory in GOPATH that older versions of goversion used,
+// or "" if it cannot be determined.
+func gopathParent() string {
 	cmd := exec.Command("go", "env", "GOPATH")
-	out, err := cmd.CombinedOutput()
+	out, err := cmd.Output()
 	if err != nil {
-		log.Fatalf("could not determine repo path: %v", err)
+		return ""
 	}
-	gopath := strings.TrimSpace(string(out))
-	list := filepath.SplitList(gopath)
-	if len(list) == 0 {
-		log.Fatalf("could not determine repo path: could not parse GOPATH=%q", gopath)
+	list := filepath.SplitList(strings.TrimSpace(string(out)))
+	if len(list) == 0 || list[0] == "" {
+		return ""
 	}
 	return filepath.Join(list[0], "src", "golang.org", "x")
 }
 
+// cmdgo returns the path of the go command of the version ref installed in parent,
+// and whether it exists, as for toolchain.GoCommand.
 func cmdgo(parent, ref string) (path string, exist bool) {
-	e := "go"
-	if runtime.GOOS == "windows" {
-		e = "go.exe"
-	}
-	path = filepath.Join(parent, ref, "bin", e)
-	_, err := os.Stat(path)
-	return path, !os.IsNotExist(err)
+	return toolchain.GoCommand(filepath.Join(parent, ref))
 }
 
+var (
+	shallow = flag.Bool("shallow", false, "clone a shallow mirror that fetches only the versions needed")
+	depth   = flag.Int("depth", 0, "clone a shallow mirror with history truncated to this many commits")
+)
+
+// updated records whether update has already run.
+var updated bool
+
+// pruneMirror makes update delete branches and tags that upstream no longer has.
+// goversion update -prune sets it.
+var pruneMirror bool
+
 // update clones or updates the Go repo.
-func update() {
+// A shallow mirror is left alone: export fetches refs into it on demand.
+// Subsequent calls do nothing.
+func update(ctx context.Context) error {
+	if updated {
+		return nil
+	}
+	updated = true
+	if err := needGit(); err != nil {
+		return err
+	}
 	parent := repoParent()
 	path := filepath.Join(parent, "go.mirror")
+	remote, err := gitRemote()
+	if err != nil {
+		return err
+	}
 	var cmd *exec.Cmd
 	var verb, gerund string
 	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if *offline {
+			return errors.New("no Go repo to use with -offline; run goversion update first")
+		}
 		// Clone repo.
-		cmd = exec.Command("git", "clone", "--bare", remote, path)
+		cmd = exec.CommandContext(ctx, "git", "clone", "--bare", remote, path)
+		switch {
+		case *depth > 0:
+			cmd.Args = append(cmd.Args, "--depth", strconv.Itoa(*depth))
+		case *shallow:
+			cmd.Args = append(cmd.Args, "--depth", "1")
+		default:
+			logf("the first clone of the Go repo downloads several hundred MB and can take minutes;\n" +
+				"use -shallow to fetch only the versions needed, or install -binary to skip it")
+		}
 		verb = "clone"
 		gerund = "cloning"
+	} else if *offline {
+		vlogf("not updating Go repo: offline")
+		return nil
+	} else if isShallow(path) {
+		vlogf("not updating shallow Go repo")
+		return nil
 	} else {
-		cmd = exec.Command("git", "fetch")
+		// Mirror branches and tags explicitly, without a remote to track them.
+		// The + accepts upstream tags and branches that moved by force.
+		cmd = exec.CommandContext(ctx, "git", "fetch", remote, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+		if pruneMirror {
+			cmd.Args = append(cmd.Args, "--prune")
+		}
 		cmd.Dir = path
 		verb = "update"
 		gerund = "updating"
 	}
+	if *quiet || *logJSON {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	log.Printf("%s Go repo", gerund)
+	logf("%s Go repo", gerund)
+	before := mirrorTags(path)
 	if err := cmd.Run(); err != nil {
-		log.Fatalf("could not %s Go repo: %v", verb, err)
+		// A clone killed partway, such as by interrupt or -timeout,
+		// must not look like a mirror to the next run.
+		if verb == "clone" {
+			os.RemoveAll(path)
+		}
+		return fmt.Errorf("could not %s Go repo: %v", verb, err)
 	}
+	if verb == "update" {
+		var added []string
+		for tag := range mirrorTags(path) {
+			if !before[tag] {
+				added = append(added, tag)
+			}
+		}
+		if len(added) > 0 {
+			sort.Slice(added, func(i, j int) bool { return toolchain.TagLess(added[i], added[j]) })
+			logf("new versions: %s", strings.Join(added, " "))
+		}
+	}
+	return nil
 }
 
-func export(ref string) {
-	parent := repoParent()
-
-	// Manually resolve ref to provide better error messages if it is bogus.
-	cmd := exec.Command("git", "rev-parse", ref)
-	cmd.Dir = filepath.Join(parent, "go.mirror")
-	if err := cmd.Run(); err != nil {
-		log.Fatalf("could not resolve %q: %v", ref, err)
+// mirrorTags returns the set of release tags in the mirror at path.
+func mirrorTags(path string) map[string]bool {
+	tags := map[string]bool{}
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/tags/go1*")
+	cmd.Dir = path
+	out, err := cmd.Output()
+	if err != nil {
+		return tags
 	}
+	for _, tag := range strings.Fields(string(out)) {
+		tags[tag] = true
+	}
+	return tags
+}
 
-	// Use git archive to generate a zip file at ref.
-	zipfile := filepath.Join(parent, ref+".zip")
-	cmd = exec.Command("git", "archive", "--format", "zip", "-o", zipfile, ref)
-	cmd.Dir = filepath.Join(parent, "go.mirror")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	// log.Printf("generating zip from Go repo at %s", ref)
-	if err := cmd.Run(); err != nil {
-		log.Fatalf("could not archive Go repo: %v", err)
+// needGit returns an error with advice if git is not installed.
+func needGit() error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("could not find git, which goversion needs to fetch Go source; install git, or use install -binary to install a prebuilt release")
 	}
-	defer os.Remove(zipfile)
+	return nil
+}
 
-	// Expand the zipfile.
-	r, err := zip.OpenReader(zipfile)
+// isShallow reports whether the mirror at path is a shallow clone.
+func isShallow(path string) bool {
+	_, err := os.Stat(filepath.Join(path, "shallow"))
+	return err == nil
+}
+
+// fetchRef fetches just ref into the shallow mirror at path.
+// ref may be a tag, a branch, or a commit.
+func fetchRef(ctx context.Context, path, ref string) error {
+	remote, err := gitRemote()
 	if err != nil {
-		log.Fatal("could not open zip: %v", err)
+		return err
 	}
-	defer r.Close()
+	refspecs := []string{
+		"+refs/tags/" + ref + ":refs/tags/" + ref,
+		"+refs/heads/" + ref + ":refs/heads/" + ref,
+		ref,
+	}
+	for _, refspec := range refspecs {
+		cmd := exec.CommandContext(ctx, "git", "fetch", "--depth", "1", remote, refspec)
+		cmd.Dir = path
+		out, cerr := cmd.CombinedOutput()
+		if cerr == nil {
+			return nil
+		}
+		err = fmt.Errorf("%v\n\n%s", cerr, out)
+	}
+	return err
+}
 
-	root := filepath.Join(parent, ref)
-	if err := os.Mkdir(root, 0755); err != nil && !os.IsExist(err) {
-		log.Fatalf("could not mkdir %s: %v", root, err)
+// export writes the source tree at ref into the directory name.
+// If it fails, the partial tree is removed.
+func export(ctx context.Context, ref, name string) (err error) {
+	if err := needGit(); err != nil {
+		return err
 	}
+	parent := repoParent()
 
-	for _, f := range r.File {
-		rc, err := f.Open()
-		if err != nil {
-			log.Fatal("could not read zip file entry %s", f.Name)
+	// Manually resolve ref to provide better error messages if it is bogus.
+	mirror := filepath.Join(parent, "go.mirror")
+	rev, err := revParse(ctx, mirror, ref)
+	if err != nil {
+		if !isShallow(mirror) || *offline {
+			return fmt.Errorf("could not resolve %q: %v", ref, err)
 		}
-		outpath := filepath.Join(root, f.Name)
-		if f.FileInfo().IsDir() {
-			// Directory
-			os.MkdirAll(outpath, f.Mode())
-			rc.Close()
-			continue
+		logf("fetching %s into shallow Go repo", ref)
+		if err := fetchRef(ctx, mirror, ref); err != nil {
+			return fmt.Errorf("could not fetch %q: %v", ref, err)
 		}
-		// File
-		os.MkdirAll(filepath.Dir(outpath), f.Mode())
-		out, err := os.OpenFile(outpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			log.Fatalf("could not create file %s: %v", outpath, err)
+		if rev, err = revParse(ctx, mirror, ref); err != nil {
+			return fmt.Errorf("could not resolve %q: %v", ref, err)
 		}
-		_, err = io.Copy(out, rc)
+	}
+
+	root := filepath.Join(parent, name)
+	if err := os.Mkdir(root, 0755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("could not mkdir %s: %v", root, err)
+	}
+	defer func() {
 		if err != nil {
-			log.Fatalf("could not write to file %s: %v", outpath, err)
+			os.RemoveAll(root)
 		}
-		out.Close()
-		rc.Close()
+	}()
+
+	// Stream a tarball of ref from git archive and expand it as it arrives.
+	cmd := exec.CommandContext(ctx, "git", "archive", "--format", "tar", rev)
+	cmd.Dir = mirror
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("could not archive Go repo: %v", err)
+	}
+	vlogf("archiving Go repo at %s", ref)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not archive Go repo: %v", err)
+	}
+	// Reap git even if extraction fails.
+	defer func() {
+		io.Copy(io.Discard, stdout)
+		if werr := cmd.Wait(); werr != nil && err == nil {
+			err = fmt.Errorf("could not archive Go repo: %v", werr)
+		}
+	}()
+	if err := toolchain.Untar(stdout, root, ""); err != nil {
+		return fmt.Errorf("could not extract archive: %v", err)
 	}
 
+	// Releases are named for their tag.
+	// Anything else is a development version, identified by commit.
+	if _, err := toolchain.ParseVersion(ref); err != nil && ref != release14 {
+		ref = "devel " + rev
+	}
+	if err := writeVersion(root, ref); err != nil {
+		return err
+	}
+	return writeOrigin(root, "git", rev)
+}
+
+// revParse returns the commit that ref names in the mirror at path.
+func revParse(ctx context.Context, path, ref string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	cmd.Dir = path
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// treeVersion returns the version recorded in the VERSION file of the Go tree root,
+// or "" if there is none.
+func treeVersion(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "VERSION"))
+	if err != nil {
+		return ""
+	}
+	// The first line of VERSION is the version; later lines hold metadata such as the build time.
+	v, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(v)
+}
+
+// writeVersion writes a VERSION file containing ref into root.
+func writeVersion(root, ref string) error {
 	vfp := filepath.Join(root, "VERSION")
 	vf, err := os.OpenFile(vfp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		log.Fatalf("could not create VERSION file: %v", err)
+		return fmt.Errorf("could not create VERSION file: %v", err)
 	}
 	if _, err := io.WriteString(vf, ref+"\n"); err != nil {
+		vf.Close()
 		os.Remove(vfp)
-		log.Fatalf("could not write VERSION file: %v", err)
+		return fmt.Errorf("could not write VERSION file: %v", err)
 	}
-	vf.Close()
+	return vf.Close()
 }
 
-func make(ref string) {
-	// Check whether we need a C compiler, and if so, whether we have one.
-	if os.Getenv("CGO_ENABLED") != "0" {
-		var havecc bool
-		ccs := []string{"gcc", "clang"}
-		if cc := os.Getenv("CC"); cc != "" {
-			ccs = append(ccs, cc)
-		}
-		for _, cc := range ccs {
-			if cc == "" {
-				continue
-			}
-			if _, err := exec.LookPath(cc); err != nil {
-				continue
-			}
-			havecc = true
-			break
-		}
-		if !havecc {
-			log.Fatalf("could not find a C compiler, tried %s", ccs)
+var jobs = flag.Int("jobs", runtime.NumCPU(), "number of CPUs to use for builds")
+
+// findCC returns the path of the C compiler that source builds will use.
+func findCC() (string, error) {
+	ccs := []string{"gcc", "clang"}
+	if cc := os.Getenv("CC"); cc != "" {
+		ccs = append(ccs, cc)
+	}
+	for _, cc := range ccs {
+		if path, err := exec.LookPath(cc); err == nil {
+			return path, nil
 		}
 	}
+	return "", fmt.Errorf("could not find a C compiler, tried %s", ccs)
+}
+
+// make builds the source tree in the directory name.
+// If target is not the host platform, make builds a cross toolchain.
+// env holds additional environment variables for the build, such as GOARM.
+// If the build fails, the directory is removed unless keepFailed is set.
+func make(ctx context.Context, name string, target platform, env []string, keepFailed bool) (err error) {
 	parent := repoParent()
-	srcdir := filepath.Join(parent, ref, "src")
+	// Remove a failed build so that the next attempt starts clean.
+	defer func() {
+		if err != nil && !keepFailed {
+			root := filepath.Join(parent, name)
+			os.RemoveAll(root)
+			logEventf(logEvent{Level: "info", Version: name, Phase: "build", Path: root}, "removed failed build %s", root)
+		}
+	}()
+	srcdir := filepath.Join(parent, name, "src")
 	var script string
 	switch runtime.GOOS {
 	case "darwin", "linux", "freebsd", "netbsd", "openbsd", "dragonfly":
@@ -277,40 +668,414 @@ func make(ref string) {
 	case "plan9":
 		script = "make.rc"
 	default:
-		log.Fatalf("unrecognized GOOS: %s", runtime.GOOS)
+		return fmt.Errorf("unrecognized GOOS: %s", runtime.GOOS)
 	}
-	mk, err := filepath.Abs(filepath.Join(parent, ref, "src", script))
+	mk, err := filepath.Abs(filepath.Join(parent, name, "src", script))
 	if err != nil {
-		log.Fatalf("could not get absolute path to %s in %s: %v", script, srcdir, err)
+		return fmt.Errorf("could not get absolute path to %s in %s: %v", script, srcdir, err)
+	}
+	// Trees from before go1, such as weekly snapshots, were built differently.
+	if _, err := os.Stat(mk); os.IsNotExist(err) {
+		return fmt.Errorf("could not build %s: no src/%s; it predates the build model goversion supports (go1 and later)", name, script)
+	}
+	ref := treeVersion(filepath.Join(parent, name))
+	cmd := exec.CommandContext(ctx, mk)
+	if runtime.GOOS == "windows" {
+		// cmd.exe mangles the quoting of batch file paths containing spaces,
+		// as in C:\Users\Jane Doe\go. Run make.bat relative to its directory instead.
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/c", script)
 	}
-	cmd := exec.Command(mk)
 	cmd.Dir = srcdir
-	log.Printf("running %s", mk)
-	out, err := cmd.CombinedOutput()
+	setProcessGroup(cmd)
+	// The go command and the compiler size their parallelism by GOMAXPROCS.
+	cmd.Env = append(os.Environ(), "GOMAXPROCS="+strconv.Itoa(*jobs))
+	if target != host() {
+		cmd.Env = append(cmd.Env, "GOOS="+target.goos, "GOARCH="+target.goarch)
+	}
+	cmd.Env = append(cmd.Env, env...)
+	// Check whether we need a C compiler, and if so, whether we have one.
+	cgoEnabled := getenv(cmd.Env, "CGO_ENABLED")
+	if builtWithC(ref) {
+		// The compilers themselves are C programs, whatever CGO_ENABLED says.
+		if _, err := findCC(); err != nil {
+			return fmt.Errorf("%v; %s is written in C and needs one to build", err, ref)
+		}
+	} else if cgoEnabled != "0" {
+		if _, err := findCC(); err != nil {
+			return fmt.Errorf("%v; use -no-cgo to build without cgo", err)
+		}
+	}
+	// Left unset, cgo is enabled for native builds and disabled for cross builds.
+	if cgoEnabled == "" {
+		cgoEnabled = "0"
+		if target == host() {
+			cgoEnabled = "1"
+		}
+	}
+	logEventf(logEvent{Level: "info", Version: name, Phase: "build", Path: mk}, "running %s", mk)
+	// Always capture the output to report failures.
+	// With -v, also show it as it happens, since builds take minutes,
+	// unless that would break up -log-json output.
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if *verbose && !*quiet && !*logJSON {
+		w = io.MultiWriter(&buf, os.Stderr)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Run()
+	out := buf.Bytes()
 	if err != nil {
-		log.Fatalf("could not build %s: %v\n\n%s", ref, err, out)
+		return fmt.Errorf("could not build %s: %v\n\n%s", name, err, out)
 	}
 	// Confirm that cmd/go got build.
 	// make.bat doesn't set its return code correctly
 	// in (at a minimum) all versions up to 1.8.1beta.
-	if _, exist := cmdgo(parent, ref); !exist {
-		log.Fatalf("could not find cmd/go:\n\n%s", out)
+	// Cross builds put the host's cmd/go in bin too.
+	if _, exist := cmdgo(parent, name); !exist {
+		return fmt.Errorf("could not find cmd/go:\n\n%s", out)
+	}
+	if err := verifyInstall(filepath.Join(parent, name)); err != nil {
+		return err
+	}
+	return writeBuildConfig(filepath.Join(parent, name), append(cmd.Env, "CGO_ENABLED="+cgoEnabled))
+}
+
+// getenv returns the value of key in env, or "" if it is not set.
+// Later entries win, as they do for exec.
+func getenv(env []string, key string) string {
+	v := ""
+	for _, kv := range env {
+		if k, val, ok := strings.Cut(kv, "="); ok && k == key {
+			v = val
+		}
+	}
+	return v
+}
+
+// verifyInstall runs the go command installed in root and checks
+// that it reports the version in root's VERSION file,
+// to catch broken toolchains before their first use.
+// Refs other than releases have devel versions, so they need only run.
+func verifyInstall(root string) error {
+	return verifyTree(root, "installed toolchain in "+root)
+}
+
+// verifyTree is like verifyInstall, but refers to the toolchain as what in errors,
+// for trees that are not yet where they will be installed.
+func verifyTree(root, what string) error {
+	want := treeVersion(root)
+	if want == "" {
+		return fmt.Errorf("could not verify %s: no VERSION file", what)
+	}
+	path, _ := cmdgo(filepath.Dir(root), filepath.Base(root))
+	cmd := exec.Command(path, "version")
+	// Run outside any module, so that GOTOOLCHAIN can't switch to another toolchain.
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "GOROOT="+root, "GOTOOLCHAIN=local")
+	out, err := cmd.CombinedOutput()
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		// Drop the path of the go command; what says which toolchain it is.
+		err = perr.Err
 	}
+	if err != nil {
+		return fmt.Errorf("%s is broken: go version failed: %v\n\n%s", what, err, out)
+	}
+	// Releases report, for example, go version go1.21.0 linux/amd64.
+	ff := strings.Fields(string(out))
+	if _, err := toolchain.ParseVersion(want); err == nil && (len(ff) < 3 || ff[2] != want) {
+		return fmt.Errorf("%s is broken: want %s, but go version printed %q", what, want, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// buildEnvVars are the environment variables that affect how make.bash
+// builds a toolchain. Source builds inherit the whole environment;
+// these are the ones recorded in build-config.
+// CGO_ENABLED is always recorded, as 1 or 0, so that build-config says whether cgo works.
+var buildEnvVars = []string{
+	"GOROOT_BOOTSTRAP", "GOOS", "GOARCH", "GOARM", "GOAMD64", "GOEXPERIMENT",
+	"CGO_ENABLED", "CC", "CC_FOR_TARGET", "CXX", "CXX_FOR_TARGET",
+	"GO_LDSO", "GO_GCFLAGS", "GO_LDFLAGS",
+}
+
+// writeBuildConfig records in root/build-config the settings of buildEnvVars in env
+// that a toolchain was built with, one KEY=VALUE per line.
+func writeBuildConfig(root string, env []string) error {
+	// Later entries in env win, as they do for exec.
+	set := map[string]string{}
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			set[k] = v
+		}
+	}
+	var buf bytes.Buffer
+	for _, k := range buildEnvVars {
+		if v, ok := set[k]; ok {
+			fmt.Fprintf(&buf, "%s=%s\n", k, v)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "build-config"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write build-config: %v", err)
+	}
+	return nil
 }
 
 const usage = `goversion is a tool to install and use multiple Go versions.
 
 Usage:
 
-        goversion list                  list known Go versions
-        goversion install <version>     install a Go version
-        goversion <version> <args>      run 'go args' using a given Go version
+        goversion list [flags]                  list Go versions available to install
+        goversion installed [-all] [-json]      list installed Go versions
+        goversion install [flags] <version>...  install Go versions
+        goversion install [flags] -ref <ref>    build and install a git branch or commit
+        goversion install -from-tarball <file> [<version>]
+                                                install a local .tar.gz or .zip archive
+        goversion uninstall [flags] <version>   remove an installed Go version
+        goversion prune [flags]                 remove old installed Go versions
+        goversion gc [-n]                       remove failed builds, partial downloads,
+                                                and an unneeded bootstrap toolchain
+        goversion default [<version>]           print or set the default Go version
+        goversion use [flags] <version>         print shell commands to use a Go version in this shell
+        goversion which [<version>]             print the path to a Go version's go command
+        goversion alias [<name> [<version>]]    list, print, or set version aliases;
+                                                the version may be an installed ref
+        goversion alias -d <name>               delete a version alias
+        goversion update [-prune]               fetch new versions into the Go mirror
+        goversion completion <shell>            print a bash, zsh, or fish completion script
+        goversion cache path|size|clean [-mirror]
+                                                show or remove cached downloads and the mirror
+        goversion freeze                        print the installed versions and where they came from
+        goversion verify <file>                 check installed versions against freeze output
+        goversion doctor                        check that the environment can install versions
+        goversion self-update                   replace goversion with its latest release
+        goversion version                       print the version of goversion itself
+        goversion env [flags] [<version>]       print shell commands to use a Go version
+        goversion run [<version>] -- <cmd>      run cmd with a Go version's GOROOT and PATH
+        goversion exec-all [flags] <args>       run 'go args' using each installed Go version
+        goversion tip [-no-update]              build the master branch and install it as tip
+        goversion tip <args>                    run 'go args' using tip
+        goversion <version> <args>              run 'go args' using a given Go version
+        goversion <args>                        run 'go args' using the project or default Go version
+
+Global flags, given before the command:
+
+        -q              print only errors
+        -v              print more output
+        -log-json       print log messages as JSON objects, one per line, with fields
+                        time, level (debug, info, warning, or error), msg, and,
+                        where they apply, version, phase, and path
+        -lock-timeout d wait up to duration d for another goversion to finish
+        -print          print the version that would be run and where it came from
+        -retries n      retry failed network requests n times (default 3)
+        -http-timeout d give up on small network requests, such as for the download
+                        index, after duration d, and on downloads that make no
+                        progress for d (default 30s; 0 means never)
+        -timeout d      abort installs and downloads after duration d, such as 30m
+        -skip-verify    do not verify checksums of downloaded archives
+        -min-free n     require n MiB of free disk space to install; 0 skips the check
+        -offline        never access the network; build from the Go mirror and
+                        use the cached download index
+        -shallow        clone a shallow mirror, fetching versions only as needed
+        -depth n        like -shallow, but clone the last n commits of each branch
+        -mirror-from-github
+                        clone and update the Go repo from https://github.com/golang/go,
+                        for where go.googlesource.com is blocked or slow. Its tags
+                        and branches are the same, but it may lag slightly behind,
+                        as just after a release; overrides GOVERSION_GIT_REMOTE
+        -jobs n         use n CPUs for builds (default all); source builds run
+                        with GOMAXPROCS=n, which bounds the go command and compiler
+        -auto-install   install a missing version before running it, asking first
+                        on a terminal
+        -y              with -auto-install, install without asking; with install,
+                        replace a version that isn't a release with the newest
+                        release of its minor release
+        -keep-env       run go with the inherited GOROOT, GOTOOLDIR, and GOTOOLCHAIN
+                        instead of pointing GOROOT at the selected version and
+                        setting GOTOOLCHAIN=local
+
+The -json flag prints a JSON array of objects with fields
+version, stable, and installed.
+
+List flags:
+
+        -desc           list the newest versions first
+        -json           print JSON
+        -mark           mark installed versions with a trailing *
+        -installed-only list only versions that are installed
+        -stable-only    omit betas and release candidates
+        -min v          list only versions v and newer
+        -max v          list only versions v and older
+        -source         list tagged releases, which can be built from source (default)
+        -binary         list versions with binary downloads for this platform
+        -all            list both, noting after each where it is available;
+                        with -json, objects also have field available
+        -platform p     with -binary or -all, use downloads for p, such as
+                        linux/arm64, instead of the host's
+        -limit n        list only the newest n of the versions that the other flags
+                        select, in the order they would be listed; for example,
+                        -stable-only -desc -limit 5 lists the last five releases
+
+Install flags:
+
+        -binary         require a prebuilt binary instead of building from source
+        -source         always build from source
+        -bootstrap v    bootstrap source builds with Go version v,
+                        installing it first if necessary. By default, source
+                        builds use the newest suitable installed release, then
+                        a suitable go on PATH, and install one only if neither
+                        exists. Releases before Go 1.5 are written in C; they
+                        need a C compiler instead, even with -no-cgo
+        -os goos        install a toolchain for goos (default the host's)
+        -arch goarch    install a toolchain for goarch (default the host's);
+                        cross toolchains are installed as <version>-<goos>-<goarch>
+        -force          reinstall even if the version is already installed
+        -keep-failed    keep the source tree of a failed build for inspection
+        -keep-archive   keep downloaded archives for inspection and print their
+                        paths; source builds stream from git and have none
+        -ref r          build git ref r, such as master or a commit, instead of
+                        a version; it is installed under a name derived from r
+        -as name        also make name an alias for the installed version or ref,
+                        as with goversion alias, so that goversion name <args> runs it
+        -sha256 hex     require the binary archive to have SHA256 digest hex, as
+                        well as its published checksum (even with -skip-verify);
+                        implies -binary. With -from-tarball, the local archive
+                        must have digest hex. Binary installs print the digest
+                        of each archive, for pinning
+        -goarm n        build from source with GOARM=n, such as 6 or 7, for arm targets
+        -goamd64 v      build from source with GOAMD64=v, such as v3, for amd64 targets;
+                        Go 1.18 and later only. Either installs as <version>-goarm<n>
+                        or <version>-goamd64<v> so that variants don't collide
+        -no-cgo         build from source with CGO_ENABLED=0, which needs no C compiler
+        -env k=v        set environment variable k to v for source builds; may be
+                        repeated. The builds inherit the environment, and the
+                        settings of GOROOT_BOOTSTRAP, GOOS, GOARCH, GOARM, GOAMD64,
+                        GOEXPERIMENT, CGO_ENABLED, CC, CC_FOR_TARGET, CXX,
+                        CXX_FOR_TARGET, GO_LDSO, GO_GCFLAGS, and GO_LDFLAGS are
+                        recorded in build-config in the installed version
+        -json           print a JSON object per version with fields version, path,
+                        method (binary, source, or none), bootstrap, sha256
+                        (of a downloaded archive), seconds, phases (the seconds
+                        spent downloading, building, and so on), ok, and error,
+                        instead of progress messages
+        -n, -dry-run    print how each version would be installed, including the
+                        bootstrap toolchain and disk space, without installing
+        -from-tarball f install the official archive f without using the network;
+                        the version comes from its VERSION file and must match
+                        the version argument, if any
+
+Uninstall takes a version or alias, or the name of an installed directory as
+listed by installed -all, such as tip, a -ref build, or go1.21.0-linux-arm64.
+
+Uninstall flags:
+
+        -force          allow removing the bootstrap toolchain or the default version,
+                        which leaves no default set
+        -n              print what would be removed without removing it
+
+Env flags:
+
+        -powershell     print PowerShell commands instead of POSIX shell commands
+        -unset          print commands that undo the changes
+
+For example, eval "$(goversion env 1.8)" makes go in the current shell mean Go 1.8.
+
+Use flags:
+
+        -powershell     print PowerShell commands instead of POSIX shell commands
+        -global         set the default version, like goversion default <version>
+
+Use affects only the shell that evaluates its output, as in
+eval "$(goversion use 1.21)"; other shells and new terminals keep the default.
+Default and use -global change the version for every shell, and persist.
+
+Prune flags:
+
+        -keep n         keep only the n newest stable versions
+        -prereleases    remove betas and release candidates
+        -before v       remove versions older than v
+        -n              print what would be removed without removing it
+
+Prune never removes the bootstrap toolchain or the default version.
+
+Gc removes toolchain directories in the root that have no working go command,
+such as failed builds, along with partial downloads and extractions and stray Go
+archives. It also removes the Go 1.4 bootstrap toolchain when no installed version
+would need it to be rebuilt. It leaves alone anything goversion did not create, such as
+other checkouts in a shared root. -n prints what would be removed, and how much space it would
+free, without removing it.
+
+Cache flags:
+
+        -mirror         with clean, also remove the Go mirror; it is cloned again
+                        by the next source build
+
+Exec-all flags:
+
+        -stop-on-fail   stop after the first version that fails
+        -versions list  use the comma-separated versions in list
+                        instead of all installed versions
+
+Tip fetches and rebuilds master each time it is run without arguments;
+-no-update rebuilds from the mirror as it is.
+
+Environment:
+
+        GOVERSION_ROOT          directory holding installed versions and the Go mirror
+                                (default $HOME/.goversion)
+        GOVERSION_CACERT        PEM file of extra CA certificates to trust
+        GOVERSION_GIT_REMOTE    URL of the Go repo to clone
+                                (default https://go.googlesource.com/go)
+        GOVERSION_DL_BASE       URL that binary archives are downloaded from
+                                (default https://storage.googleapis.com/golang/)
+        GOVERSION_DL_INDEX      URL of the index of binary archives
+                                (default https://storage.googleapis.com/go-builder-data/dl-index.txt)
+        GOVERSION_AUTO_INSTALL  set to 1 to act as if -auto-install were given
+        GOVERSION_HTTP_TIMEOUT  default for -http-timeout, such as 2m
+        GOVERSION_RELEASES_URL  URL describing the latest goversion release, for self-update
+                                (default the GitHub releases API)
+        GOVERSION_CONFIG        config file to read (default goversion/config in the
+                                user config directory, such as ~/.config/goversion/config)
+        HTTP_PROXY, HTTPS_PROXY, NO_PROXY
+                                proxy configuration for downloads
+
+The config file sets defaults, one key = value per line; # starts a comment:
+
+        root = /opt/goversion   like GOVERSION_ROOT; must be absolute
+        git-remote = <url>      like GOVERSION_GIT_REMOTE
+        dl-base = <url>         like GOVERSION_DL_BASE
+        dl-index = <url>        like GOVERSION_DL_INDEX
+        offline = true          like -offline
+        skip-verify = true      like -skip-verify
+        mirror-from-github = true
+                                like -mirror-from-github; GOVERSION_GIT_REMOTE
+                                overrides it, and git-remote conflicts with it
+
+Flags override environment variables, which override the config file,
+which overrides the built-in defaults.
 
 For example:
 
 goversion install 1.8beta1
 goversion 1.8beta1 test ./...
 
+The version latest refers to the newest stable release.
+Aliases, such as ci after goversion alias ci 1.21.5, may be used wherever
+a version may; an alias for latest follows new releases.
+On a terminal, install asks which release of a minor release, such as 1.21,
+to install, and which release to install instead of a version that isn't one;
+-y picks the newest release of the same minor release. Likewise, running a
+version that isn't installed offers installed releases of its minor release.
+
+When no version is given, goversion uses the version named in the nearest
+.go-version file in the current directory or its parents,
+and otherwise the default version.
+
+When running go or another command, goversion exits with the command's exit
+code, or, where there are signals, 128 plus the number of the signal that
+killed it.
+
 `
 
 func printUsage() {
@@ -318,86 +1083,578 @@ func printUsage() {
 	os.Exit(2)
 }
 
-// version converts versions to have a go prefix and reports whether it looks like a go version.
-// For example, go1.7.4 and 1.7.4 both return go1.7.4, true.
-func version(s string) (string, bool) {
-	// Accept both go1.7.4 and 1.7.4.
-	s = strings.TrimPrefix(s, "go")
-	if !strings.HasPrefix(s, "1") {
-		return "", false
-	}
-	return "go" + s, true
-}
+var timeout = flag.Duration("timeout", 0, "abort after this long; 0 means no limit")
 
 func main() {
 	log.SetFlags(0)
 	flag.Parse()
 
-	if flag.NArg() < 1 {
+	// Cancel long operations on interrupt, killing any child processes.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if flag.NArg() < 1 && !*printResolved {
 		printUsage()
 	}
 
+	// Errors are fatal only here, so that commands can clean up after themselves,
+	// including releasing the lock, on their way out.
+	if err := goversion(ctx); err != nil {
+		var nie *toolchain.NotInstalledError
+		if errors.As(err, &nie) {
+			fatalf("%v. Have you run %s %s?", err, os.Args[0], installArgs(nie.Version))
+		}
+		fatalf("%v", err)
+	}
+}
+
+// goversion runs the command given on the command line.
+func goversion(ctx context.Context) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	var err error
+	if parentDir, err = findRepoParent(); err != nil {
+		return err
+	}
+	if *jobs < 1 {
+		return errors.New("-jobs must be at least 1")
+	}
+
 	switch flag.Arg(0) {
 	case "list":
-		list()
-		return
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		fs.Usage = printUsage
+		var opt listOptions
+		fs.BoolVar(&opt.desc, "desc", false, "list newest versions first")
+		fs.BoolVar(&opt.asJSON, "json", false, "print JSON")
+		fs.BoolVar(&opt.mark, "mark", false, "mark installed versions with *")
+		fs.BoolVar(&opt.installedOnly, "installed-only", false, "list only installed versions")
+		fs.BoolVar(&opt.stableOnly, "stable-only", false, "omit betas and release candidates")
+		min := fs.String("min", "", "list only versions at least this one")
+		max := fs.String("max", "", "list only versions at most this one")
+		source := fs.Bool("source", false, "list tagged releases, which can be built from source")
+		fs.BoolVar(&opt.binary, "binary", false, "list versions with binary downloads")
+		fs.BoolVar(&opt.all, "all", false, "list both, noting where each is available")
+		plat := fs.String("platform", "", "with -binary or -all, the GOOS/GOARCH of the downloads")
+		fs.IntVar(&opt.limit, "limit", 0, "list only the newest n versions")
+		fs.Parse(flag.Args()[1:])
+		if *source && opt.binary || *source && opt.all || opt.binary && opt.all || *plat != "" && !opt.binary && !opt.all {
+			printUsage()
+		}
+		if opt.limit < 0 {
+			return errors.New("-limit must not be negative")
+		}
+		if *plat != "" {
+			if opt.platform, err = parsePlatform(*plat); err != nil {
+				return err
+			}
+		}
+		if *min != "" {
+			ref, err := versionArg(ctx, *min)
+			if err != nil {
+				return err
+			}
+			opt.min, _ = toolchain.ParseVersion(ref)
+			opt.hasMin = true
+		}
+		if *max != "" {
+			ref, err := versionArg(ctx, *max)
+			if err != nil {
+				return err
+			}
+			opt.max, _ = toolchain.ParseVersion(ref)
+			opt.hasMax = true
+		}
+		return list(ctx, &opt)
 	case "listdl":
-		listdl()
-		return
+		// Superseded by list -binary, but kept for compatibility.
+		fs := flag.NewFlagSet("listdl", flag.ExitOnError)
+		fs.Usage = printUsage
+		asJSON := fs.Bool("json", false, "print JSON")
+		plat := fs.String("platform", "", "list downloads for this GOOS/GOARCH instead of the host's")
+		all := fs.Bool("all-platforms", false, "list every version with the platforms it has downloads for")
+		fs.Parse(flag.Args()[1:])
+		if *all {
+			if *plat != "" {
+				printUsage()
+			}
+			return listdlAll(ctx, *asJSON)
+		}
+		p := host()
+		if *plat != "" {
+			if p, err = parsePlatform(*plat); err != nil {
+				return err
+			}
+		}
+		return listdl(ctx, p, *asJSON)
 	case "update":
-		// Intentionally undocumented, useful during testing.
-		update()
-		return
+		fs := flag.NewFlagSet("update", flag.ExitOnError)
+		fs.Usage = printUsage
+		fs.BoolVar(&pruneMirror, "prune", false, "remove branches and tags deleted upstream")
+		fs.Parse(flag.Args()[1:])
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return update(ctx)
 	case "export":
 		// Intentionally undocumented, useful during testing.
-		update()
 		if flag.NArg() < 2 {
 			printUsage()
 		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := update(ctx); err != nil {
+			return err
+		}
 		ref := flag.Arg(1)
-		export(ref)
-		return
+		return export(ctx, ref, ref)
+	case "unpack":
+		// Intentionally undocumented, useful during testing.
+		if flag.NArg() < 2 {
+			printUsage()
+		}
+		ref, err := versionArg(ctx, flag.Arg(1))
+		if err != nil {
+			return err
+		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		dir, err := os.MkdirTemp(repoParent(), ".download")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path, _, err := download(ctx, ref, host(), dir, "")
+		if err != nil {
+			return fmt.Errorf("could not download %s: %v", ref, err)
+		}
+		return unpack(ref, ref, path)
 	case "install":
-		update()
+		fs := flag.NewFlagSet("install", flag.ExitOnError)
+		fs.Usage = printUsage
+		var opt installOptions
+		fs.BoolVar(&opt.binary, "binary", false, "require a prebuilt binary")
+		fs.BoolVar(&opt.source, "source", false, "always build from source")
+		fs.StringVar(&opt.bootstrap, "bootstrap", "", "Go version to bootstrap source builds with")
+		fs.StringVar(&opt.target.goos, "os", runtime.GOOS, "target GOOS")
+		fs.StringVar(&opt.target.goarch, "arch", runtime.GOARCH, "target GOARCH")
+		fs.BoolVar(&opt.force, "force", false, "reinstall even if already installed")
+		fs.BoolVar(&opt.keepFailed, "keep-failed", false, "keep the source tree of a failed build")
+		fs.BoolVar(&opt.keepArchive, "keep-archive", false, "keep downloaded archives")
+		fs.StringVar(&opt.goarm, "goarm", "", "GOARM for source builds")
+		fs.StringVar(&opt.goamd64, "goamd64", "", "GOAMD64 for source builds")
+		fs.BoolVar(&opt.asJSON, "json", false, "print the result of each install as JSON")
+		fs.Var(&opt.env, "env", "KEY=VALUE to set for source builds; may be repeated")
+		fs.BoolVar(&opt.noCgo, "no-cgo", false, "build from source with CGO_ENABLED=0")
+		fs.BoolVar(&opt.dryrun, "n", false, "print what would be done without doing it")
+		fs.BoolVar(&opt.dryrun, "dry-run", false, "print what would be done without doing it")
+		gitref := fs.String("ref", "", "build an arbitrary git ref instead of a version")
+		tarball := fs.String("from-tarball", "", "install from a local archive")
+		fs.StringVar(&opt.as, "as", "", "also make this alias for the installed version")
+		fs.StringVar(&opt.sha256, "sha256", "", "SHA256 that the binary archive must have")
+		fs.Parse(flag.Args()[1:])
+		if opt.binary && opt.source {
+			printUsage()
+		}
+		// Catch typos now rather than deep in a build.
+		if err := opt.target.check(); err != nil {
+			return err
+		}
+		dryRun = opt.dryrun
+		if *tarball != "" {
+			if fs.NArg() > 1 || *gitref != "" || opt.binary || opt.source {
+				printUsage()
+			}
+			var want string
+			if fs.NArg() == 1 {
+				if want, err = versionArg(ctx, fs.Arg(0)); err != nil {
+					return err
+				}
+			}
+			if opt.sha256 != "" {
+				if opt.sha256, err = checkSHA256(opt.sha256); err != nil {
+					return err
+				}
+			}
+			if opt.as != "" {
+				if err := checkAliasName(opt.as); err != nil {
+					return err
+				}
+			}
+			if opt.dryrun {
+				return printTarballPlan(*tarball, want, opt.sha256, opt.force)
+			}
+			if opt.asJSON {
+				*quiet = true
+			}
+			unlock, err := lock()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			if opt.asJSON {
+				return installTarballJSON(ctx, *tarball, want, &opt)
+			}
+			return installTarball(ctx, *tarball, want, &opt)
+		}
+		var refs []string
+		if *gitref != "" {
+			if fs.NArg() != 0 || opt.binary {
+				printUsage()
+			}
+			name, err := refName(*gitref)
+			if err != nil {
+				return err
+			}
+			refs = []string{*gitref}
+			opt.source = true
+			opt.name = installName(name, opt.target)
+		} else {
+			if fs.NArg() < 1 {
+				printUsage()
+			}
+			for _, arg := range fs.Args() {
+				ref, err := versionArg(ctx, arg)
+				if err != nil {
+					return err
+				}
+				if ref, err = chooseRelease(ctx, arg, ref); err != nil {
+					return err
+				}
+				refs = append(refs, ref)
+			}
+		}
+		if opt.as != "" {
+			if len(refs) != 1 {
+				printUsage()
+			}
+			if err := checkAliasName(opt.as); err != nil {
+				return err
+			}
+		}
+		if opt.sha256 != "" {
+			// A pinned archive is a binary; building from source would bypass the pin.
+			if len(refs) != 1 || *gitref != "" || opt.source {
+				printUsage()
+			}
+			if opt.sha256, err = checkSHA256(opt.sha256); err != nil {
+				return err
+			}
+			opt.binary = true
+		}
+		if opt.bootstrap != "" && opt.bootstrap != release14 {
+			if opt.bootstrap, err = versionArg(ctx, opt.bootstrap); err != nil {
+				return err
+			}
+		}
+		if opt.dryrun {
+			return installAll(ctx, refs, &opt)
+		}
+		if opt.asJSON {
+			// The JSON is the report; keep progress messages out of the way.
+			*quiet = true
+		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return installAll(ctx, refs, &opt)
+	case "installed":
+		fs := flag.NewFlagSet("installed", flag.ExitOnError)
+		fs.Usage = printUsage
+		all := fs.Bool("all", false, "include the bootstrap toolchain")
+		asJSON := fs.Bool("json", false, "print JSON")
+		fs.Parse(flag.Args()[1:])
+		vers, err := installed(*all)
+		if err != nil {
+			return err
+		}
+		return printVersions(vers, *asJSON)
+	case "default":
+		if flag.NArg() < 2 {
+			ref := defaultVersion()
+			if ref == "" {
+				return errors.New("no default version set")
+			}
+			fmt.Println(ref)
+			return nil
+		}
+		ref, err := versionArg(ctx, flag.Arg(1))
+		if err != nil {
+			return err
+		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return setDefault(ref)
+	case "tip":
+		fs := flag.NewFlagSet("tip", flag.ExitOnError)
+		fs.Usage = printUsage
+		noUpdate := fs.Bool("no-update", false, "rebuild without fetching upstream")
+		fs.Parse(flag.Args()[1:])
+		if fs.NArg() > 0 {
+			path, err := goPath(tipName)
+			if err != nil {
+				return err
+			}
+			return execute(goCommand(path, fs.Args()...))
+		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return tip(ctx, *noUpdate)
+	case "which":
+		var ref string
 		if flag.NArg() < 2 {
+			ref, err = resolveArg(ctx)
+		} else {
+			ref, err = versionArg(ctx, flag.Arg(1))
+		}
+		if err != nil {
+			return err
+		}
+		path, err := goPath(ref)
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	case "gc":
+		fs := flag.NewFlagSet("gc", flag.ExitOnError)
+		fs.Usage = printUsage
+		dryrun := fs.Bool("n", false, "print what would be removed")
+		fs.Parse(flag.Args()[1:])
+		if fs.NArg() != 0 {
 			printUsage()
 		}
-		ref, ok := version(flag.Arg(1))
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return gc(ctx, *dryrun)
+	case "prune":
+		fs := flag.NewFlagSet("prune", flag.ExitOnError)
+		fs.Usage = printUsage
+		var opt pruneOptions
+		fs.IntVar(&opt.keep, "keep", 0, "keep only the n newest stable versions")
+		fs.BoolVar(&opt.prereleases, "prereleases", false, "remove betas and release candidates")
+		before := fs.String("before", "", "remove versions older than this one")
+		fs.BoolVar(&opt.dryrun, "n", false, "print what would be removed")
+		fs.Parse(flag.Args()[1:])
+		if *before != "" {
+			ref, err := versionArg(ctx, *before)
+			if err != nil {
+				return err
+			}
+			opt.before, _ = toolchain.ParseVersion(ref)
+			opt.hasBefore = true
+		}
+		if opt.keep <= 0 && !opt.prereleases && !opt.hasBefore {
+			printUsage()
+		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return prune(&opt)
+	case "env":
+		fs := flag.NewFlagSet("env", flag.ExitOnError)
+		fs.Usage = printUsage
+		powershell := fs.Bool("powershell", false, "print PowerShell commands")
+		unset := fs.Bool("unset", false, "print commands that undo the environment changes")
+		fs.Parse(flag.Args()[1:])
+		var ref string
+		switch {
+		case *unset:
+		case fs.NArg() > 0:
+			ref, err = versionArg(ctx, fs.Arg(0))
+		default:
+			ref, err = resolveArg(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		return printEnv(ref, *powershell, *unset)
+	case "use":
+		fs := flag.NewFlagSet("use", flag.ExitOnError)
+		fs.Usage = printUsage
+		powershell := fs.Bool("powershell", false, "print PowerShell commands")
+		global := fs.Bool("global", false, "set the default version instead")
+		fs.Parse(flag.Args()[1:])
+		if fs.NArg() != 1 {
+			printUsage()
+		}
+		ref, err := versionArg(ctx, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if *global {
+			unlock, err := lock()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return setDefault(ref)
+		}
+		return printEnv(ref, *powershell, false)
+	case "alias":
+		fs := flag.NewFlagSet("alias", flag.ExitOnError)
+		fs.Usage = printUsage
+		del := fs.Bool("d", false, "delete the alias")
+		fs.Parse(flag.Args()[1:])
+		switch {
+		case *del && fs.NArg() == 1, fs.NArg() == 2:
+			unlock, err := lock()
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			if *del {
+				return unalias(fs.Arg(0))
+			}
+			return alias(ctx, fs.Arg(0), fs.Arg(1))
+		case *del, fs.NArg() > 2:
+			printUsage()
+		}
+		return printAliases(fs.Args()...)
+	case "run":
+		ref, cmdline, ok, err := runArgs(ctx, flag.Args()[1:])
+		if err != nil {
+			return err
+		}
 		if !ok {
+			// Not goversion run; treat it as go run.
+			break
+		}
+		if ref == "" {
+			if ref, err = resolveArg(ctx); err != nil {
+				return err
+			}
+		}
+		return run(ctx, ref, cmdline)
+	case "exec-all":
+		fs := flag.NewFlagSet("exec-all", flag.ExitOnError)
+		fs.Usage = printUsage
+		var opt execAllOptions
+		fs.BoolVar(&opt.stopOnFail, "stop-on-fail", false, "stop after the first version that fails")
+		versions := fs.String("versions", "", "comma-separated versions to use instead of all installed versions")
+		fs.Parse(flag.Args()[1:])
+		if fs.NArg() < 1 {
 			printUsage()
 		}
-
-		parent := repoParent()
-		bootstrap := filepath.Join(parent, release14)
-		_, exist := cmdgo(parent, release14)
-		if !exist {
-			export(release14)
-			make(release14)
+		if *versions != "" {
+			for _, v := range strings.Split(*versions, ",") {
+				ref, err := versionArg(ctx, strings.TrimSpace(v))
+				if err != nil {
+					return err
+				}
+				opt.versions = append(opt.versions, ref)
+			}
 		}
-		os.Setenv("GOROOT_BOOTSTRAP", bootstrap)
-
-		export(ref)
-		make(ref)
-		return
+		return execAll(ctx, fs.Args(), &opt)
+	case "doctor":
+		return doctor(ctx)
+	case "freeze":
+		return freeze()
+	case "verify":
+		if flag.NArg() != 2 {
+			printUsage()
+		}
+		return verify(flag.Arg(1))
+	case "cache":
+		fs := flag.NewFlagSet("cache", flag.ExitOnError)
+		fs.Usage = printUsage
+		mirror := fs.Bool("mirror", false, "also remove the Go mirror")
+		if flag.NArg() < 2 {
+			printUsage()
+		}
+		// The action comes first, as in goversion cache clean -mirror.
+		fs.Parse(flag.Args()[2:])
+		if fs.NArg() != 0 {
+			printUsage()
+		}
+		return cache(flag.Arg(1), *mirror)
+	case "version":
+		printToolVersion()
+		return nil
+	case "self-update":
+		return selfUpdate(ctx)
+	case "completion":
+		if flag.NArg() < 2 {
+			printUsage()
+		}
+		return completion(flag.Arg(1))
+	case "uninstall":
+		fs := flag.NewFlagSet("uninstall", flag.ExitOnError)
+		fs.Usage = printUsage
+		force := fs.Bool("force", false, "remove the bootstrap toolchain or the default version")
+		dryrun := fs.Bool("n", false, "print what would be removed")
+		fs.Parse(flag.Args()[1:])
+		if fs.NArg() < 1 {
+			printUsage()
+		}
+		ref, err := uninstallArg(ctx, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		unlock, err := lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return uninstall(ref, *force, *dryrun)
 	}
 
-	ref, ok := version(flag.Arg(0))
-	if !ok {
-		printUsage()
+	args := flag.Args()
+	var ref, source string
+	if len(args) > 0 {
+		ref, err = version(ctx, args[0])
+	}
+	switch {
+	case len(args) > 0 && err == nil:
+		args = args[1:]
+		source = "command line"
+	case len(args) > 0 && err != errNotVersion:
+		return err
+	default:
+		// Not a version; pass all arguments to the resolved version.
+		if ref, source, err = resolve(ctx); err != nil {
+			return err
+		}
+		if ref == "" {
+			printUsage()
+		}
+	}
+	if *printResolved {
+		fmt.Printf("%s (%s)\n", ref, source)
+		return nil
 	}
 
 	// Execute command with the requested version.
-	parent := repoParent()
-	path, exist := cmdgo(parent, ref)
-	if !exist {
-		log.Fatalf("%s not found. Have you run %s install %s?", path, os.Args[0], ref)
-	}
-	cmd := exec.Command(path, flag.Args()[1:]...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		// TODO: Attempt to replicate original rc?
-		os.Exit(1)
+	path, err := ensureInstalled(ctx, ref)
+	if err != nil {
+		return err
 	}
+	return execute(goCommand(path, args...))
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCmdgo(t *testing.T) {
+	for _, name := range []string{"go", "go.exe"} {
+		parent := t.TempDir()
+		if _, exist := cmdgo(parent, "go1.21.0"); exist {
+			t.Errorf("cmdgo reports an empty root as installed")
+		}
+		bin := filepath.Join(parent, "go1.21.0", "bin")
+		if err := os.MkdirAll(bin, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, exist := cmdgo(parent, "go1.21.0"); exist {
+			t.Errorf("cmdgo reports an empty bin directory as installed")
+		}
+		want := filepath.Join(bin, name)
+		if err := os.WriteFile(want, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path, exist := cmdgo(parent, "go1.21.0")
+		if !exist || path != want {
+			t.Errorf("with bin/%s, cmdgo = %q, %v; want %q, true", name, path, exist, want)
+		}
+	}
+}
+
+// errReader reads r, then fails with err instead of io.EOF.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		err = e.err
+	}
+	return n, err
+}
+
+func TestScanDlVersions(t *testing.T) {
+	const index = "https://dl.google.com/go/go1.21.0.linux-amd64.tar.gz\n" +
+		"https://dl.google.com/go/go1.21.0.linux-arm64.tar.gz\n" +
+		"https://dl.google.com/go/go1.21.1.linux-amd64.tar.gz\n"
+	linux := platform{"linux", "amd64"}
+	vers, err := scanDlVersions(strings.NewReader(index), linux)
+	if want := []string{"go1.21.0", "go1.21.1"}; err != nil || !reflect.DeepEqual(vers, want) {
+		t.Errorf("scanDlVersions = %q, %v; want %q, nil", vers, err, want)
+	}
+
+	broken := errors.New("connection reset")
+	r := &errReader{strings.NewReader(index[:len(index)/2]), broken}
+	vers, err = scanDlVersions(r, linux)
+	if err == nil || !strings.Contains(err.Error(), broken.Error()) {
+		t.Errorf("scanDlVersions of a failing reader = %q, %v; want error containing %q", vers, err, broken)
+	}
+}
+
+// testMirror makes a Go mirror in parent whose one commit, tagged go1.21.0,
+// holds src/make.bash, and points repoParent at parent until the test ends.
+func testMirror(t *testing.T, parent string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	gitIn := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	src := filepath.Join(parent, "src repo")
+	if err := os.MkdirAll(filepath.Join(src, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "src", "make.bash"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(src, "init", "-q")
+	gitIn(src, "add", ".")
+	gitIn(src, "commit", "-q", "-m", "initial")
+	gitIn(src, "tag", "go1.21.0")
+	gitIn(parent, "clone", "-q", "--mirror", src, "go.mirror")
+	old := parentDir
+	t.Cleanup(func() { parentDir = old })
+	parentDir = parent
+}
+
+func TestExportWithSpace(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "with space")
+	testMirror(t, parent)
+	if err := export(context.Background(), "go1.21.0", "go1.21.0"); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(parent, "go1.21.0")
+	if _, err := os.Stat(filepath.Join(root, "src", "make.bash")); err != nil {
+		t.Error(err)
+	}
+	if v := treeVersion(root); v != "go1.21.0" {
+		t.Errorf("VERSION is %q; want go1.21.0", v)
+	}
+	if origin, err := readOrigin(root); err != nil || !strings.HasPrefix(origin, "git ") {
+		t.Errorf("origin is %q, %v; want git <commit>", origin, err)
+	}
+}
+
+// TestExportError checks that export reports failures as errors
+// with their details formatted in, rather than exiting.
+func TestExportError(t *testing.T) {
+	parent := t.TempDir()
+	testMirror(t, parent)
+	err := export(context.Background(), "go1.99.0", "go1.99.0")
+	if want := `could not resolve "go1.99.0": `; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("export of a missing ref: %v; want error starting %q", err, want)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "go1.99.0")); !os.IsNotExist(err) {
+		t.Errorf("export of a missing ref left %s behind", filepath.Join(parent, "go1.99.0"))
+	}
+}
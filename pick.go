@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// family returns the versions in vers from the same minor release as ref,
+// such as go1.21.0 through go1.21.13 for go1.21, sorted oldest first.
+func family(ref string, vers []string) []string {
+	rv, err := toolchain.ParseVersion(ref)
+	if err != nil {
+		return nil
+	}
+	var fam []string
+	for _, s := range vers {
+		v, err := toolchain.ParseVersion(s)
+		if err == nil && v.Major == rv.Major && v.Minor == rv.Minor {
+			fam = append(fam, s)
+		}
+	}
+	sort.Slice(fam, func(i, j int) bool { return toolchain.TagLess(fam[i], fam[j]) })
+	return fam
+}
+
+// bestOf returns the newest stable version in vers, which is sorted oldest first,
+// or the newest version if none is stable.
+func bestOf(vers []string) string {
+	for i := len(vers) - 1; i >= 0; i-- {
+		if v, _ := toolchain.ParseVersion(vers[i]); v.Stable() {
+			return vers[i]
+		}
+	}
+	return vers[len(vers)-1]
+}
+
+// interactive reports whether goversion can ask the user questions.
+func interactive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stderr)
+}
+
+// pick asks the user to choose one of choices by number, after printing msg.
+// An empty answer chooses def, if it is one of choices, and otherwise chooses nothing.
+// pick returns "" if nothing was chosen.
+func pick(msg string, choices []string, def string) (string, error) {
+	fmt.Fprintln(os.Stderr, msg)
+	defn := 0
+	for i, c := range choices {
+		fmt.Fprintf(os.Stderr, "\t%d) %s\n", i+1, c)
+		if c == def {
+			defn = i + 1
+		}
+	}
+	rng := "1"
+	if len(choices) > 1 {
+		rng += "-" + strconv.Itoa(len(choices))
+	}
+	if defn > 0 {
+		fmt.Fprintf(os.Stderr, "Choose %s [%d]: ", rng, defn)
+	} else {
+		fmt.Fprintf(os.Stderr, "Choose %s, or press Enter to cancel: ", rng)
+	}
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		if defn > 0 {
+			return def, nil
+		}
+		return "", nil
+	}
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 1 || n > len(choices) {
+		return "", fmt.Errorf("invalid choice %q", answer)
+	}
+	return choices[n-1], nil
+}
+
+// chooseRelease returns the release to install for ref, the version that arg names.
+// On a terminal, if arg names just a minor release, such as 1.21,
+// the user picks one of its releases, with ref as the default.
+// If ref is not a release but its minor release has some, as for a mistyped go1.21.99,
+// the user picks one on a terminal, and -y picks the newest stable one.
+// Otherwise ref is returned unchanged, so that scripts get what they asked for.
+func chooseRelease(ctx context.Context, arg, ref string) (string, error) {
+	if _, err := toolchain.ParseVersion(ref); err != nil {
+		return ref, nil
+	}
+	if _, err := goPath(ref); err == nil {
+		return ref, nil
+	}
+	ask := interactive() && !*assumeYes
+	num := strings.TrimPrefix(arg, "go")
+	minorOnly := strings.Count(num, ".") == 1 && !strings.ContainsAny(num, "abcdefghijklmnopqrstuvwxyz")
+	if !ask && (minorOnly || !*assumeYes) {
+		return ref, nil
+	}
+	rels, err := releases(ctx)
+	if err != nil {
+		vlogf("could not check that %s is a release: %v", ref, err)
+		return ref, nil
+	}
+	fam := family(ref, rels)
+	isRelease := false
+	for _, r := range fam {
+		isRelease = isRelease || r == ref
+	}
+	switch {
+	case len(fam) == 0:
+		// Let the install fail with a better explanation, such as a stale mirror.
+		return ref, nil
+	case isRelease && minorOnly && len(fam) > 1:
+		return pick(fmt.Sprintf("Releases of %s:", minorOf(ref)), fam, ref)
+	case isRelease:
+		return ref, nil
+	}
+	best := bestOf(fam)
+	if !ask {
+		logf("%s is not a release; installing %s", ref, best)
+		return best, nil
+	}
+	return pick(fmt.Sprintf("%s is not a release. Releases of %s:", ref, minorOf(ref)), fam, best)
+}
+
+// chooseInstalled offers the user, on a terminal, installed versions from the same
+// minor release as ref to use instead of ref, which is not installed;
+// for example, go1.21.5 for go1.21.4.
+// It returns "" if there are none, none was chosen, or there is no terminal to ask on,
+// so that scripts never silently run a version other than the one they asked for.
+func chooseInstalled(ref string) (string, error) {
+	if *assumeYes || !interactive() {
+		return "", nil
+	}
+	vers, err := installed(false)
+	if err != nil {
+		return "", err
+	}
+	fam := family(ref, vers)
+	if len(fam) == 0 {
+		return "", nil
+	}
+	return pick(fmt.Sprintf("%s is not installed. Installed versions of %s:", ref, minorOf(ref)), fam, "")
+}
+
+// minorOf returns the minor release of the version ref, such as go1.21 for go1.21.4.
+func minorOf(ref string) string {
+	v, _ := toolchain.ParseVersion(ref)
+	return fmt.Sprintf("go%d.%d", v.Major, v.Minor)
+}
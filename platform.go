@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// A platform is a GOOS/GOARCH pair that a toolchain targets.
+type platform struct {
+	goos, goarch string
+}
+
+// host returns the platform goversion is running on.
+func host() platform {
+	return platform{runtime.GOOS, runtime.GOARCH}
+}
+
+func (p platform) String() string {
+	return p.goos + "/" + p.goarch
+}
+
+// parsePlatform parses a platform written as GOOS/GOARCH, such as linux/arm64.
+func parsePlatform(s string) (platform, error) {
+	goos, goarch, ok := strings.Cut(s, "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return platform{}, fmt.Errorf("malformed platform %q; want GOOS/GOARCH", s)
+	}
+	p := platform{goos, goarch}
+	return p, p.check()
+}
+
+// knownPlatforms are the platforms that some Go release supports,
+// as listed by go tool dist list, plus ports that have since been removed,
+// such as darwin/386, so that old releases can still be built for them.
+// New ports must be added here before goversion installs toolchains for them.
+var knownPlatforms = []platform{
+	{"aix", "ppc64"},
+	{"android", "386"}, {"android", "amd64"}, {"android", "arm"}, {"android", "arm64"},
+	{"darwin", "386"}, {"darwin", "amd64"}, {"darwin", "arm"}, {"darwin", "arm64"},
+	{"dragonfly", "amd64"},
+	{"freebsd", "386"}, {"freebsd", "amd64"}, {"freebsd", "arm"}, {"freebsd", "arm64"}, {"freebsd", "riscv64"},
+	{"illumos", "amd64"},
+	{"ios", "amd64"}, {"ios", "arm64"},
+	{"js", "wasm"},
+	{"linux", "386"}, {"linux", "amd64"}, {"linux", "arm"}, {"linux", "arm64"}, {"linux", "loong64"},
+	{"linux", "mips"}, {"linux", "mips64"}, {"linux", "mips64le"}, {"linux", "mipsle"},
+	{"linux", "ppc64"}, {"linux", "ppc64le"}, {"linux", "riscv64"}, {"linux", "s390x"},
+	{"nacl", "386"}, {"nacl", "amd64p32"}, {"nacl", "arm"},
+	{"netbsd", "386"}, {"netbsd", "amd64"}, {"netbsd", "arm"}, {"netbsd", "arm64"},
+	{"openbsd", "386"}, {"openbsd", "amd64"}, {"openbsd", "arm"}, {"openbsd", "arm64"},
+	{"openbsd", "ppc64"}, {"openbsd", "riscv64"},
+	{"plan9", "386"}, {"plan9", "amd64"}, {"plan9", "arm"},
+	{"solaris", "amd64"},
+	{"wasip1", "wasm"},
+	{"windows", "386"}, {"windows", "amd64"}, {"windows", "arm"}, {"windows", "arm64"},
+}
+
+// check returns an error if p is not a known platform,
+// listing the valid architectures for its GOOS, or the valid GOOS values if there are none.
+func (p platform) check() error {
+	if p == host() {
+		return nil
+	}
+	var arches []string
+	gooses := map[string]bool{}
+	for _, k := range knownPlatforms {
+		if k == p {
+			return nil
+		}
+		if k.goos == p.goos {
+			arches = append(arches, k.String())
+		}
+		gooses[k.goos] = true
+	}
+	if len(arches) > 0 {
+		return fmt.Errorf("unknown platform %s; the platforms for GOOS %s are %s", p, p.goos, strings.Join(arches, ", "))
+	}
+	var list []string
+	for goos := range gooses {
+		list = append(list, goos)
+	}
+	sort.Strings(list)
+	return fmt.Errorf("unknown platform %s; GOOS must be one of %s", p, strings.Join(list, ", "))
+}
+
+// installName returns the name of the directory in which to install ref for p.
+// Native toolchains are named after the version;
+// cross toolchains get a platform suffix so that they don't collide.
+func installName(ref string, p platform) string {
+	if p == host() {
+		return ref
+	}
+	return ref + "-" + p.goos + "-" + p.goarch
+}
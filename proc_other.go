@@ -0,0 +1,19 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package main
+
+import "os/exec"
+
+// setProcessGroup does nothing on systems without process groups.
+// Cancellation kills only cmd itself.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// exitCode returns the exit code of the process of err,
+// or 1 if it did not exit normally.
+func exitCode(err *exec.ExitError) int {
+	if code := err.ExitCode(); code >= 0 {
+		return code
+	}
+	return 1
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup arranges for cmd to run in a new process group
+// and for cancellation of its context to kill cmd and all of its descendants.
+// Windows has no way to signal a group that isn't attached to our console,
+// so we ask taskkill to walk the process tree.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
+
+// exitCode returns the exit code of the process of err.
+// Windows has no signals; a killed process has the exit code it was killed with.
+func exitCode(err *exec.ExitError) int {
+	return err.ExitCode()
+}
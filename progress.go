@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progress is an io.Reader that reports how much of r has been read to stderr.
+type progress struct {
+	r     io.Reader
+	total int64 // total bytes expected, or -1 if unknown
+	n     int64 // bytes read so far
+	start time.Time
+	last  time.Time
+}
+
+// newProgress returns a progress reader for r, which is expected to hold total bytes.
+func newProgress(r io.Reader, total int64) *progress {
+	now := time.Now()
+	return &progress{r: r, total: total, start: now, last: now}
+}
+
+func (p *progress) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if now := time.Now(); now.Sub(p.last) >= 250*time.Millisecond {
+		p.last = now
+		p.print(now)
+	}
+	return n, err
+}
+
+// print writes the current progress line.
+func (p *progress) print(now time.Time) {
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%s", fmtsize(p.n))
+		return
+	}
+	const width = 30
+	frac := float64(p.n) / float64(p.total)
+	if frac > 1 {
+		frac = 1
+	}
+	fill := int(frac * width)
+	bar := strings.Repeat("=", fill) + strings.Repeat(" ", width-fill)
+	eta := "?"
+	if p.n > 0 {
+		elapsed := now.Sub(p.start)
+		remain := time.Duration(float64(elapsed) * float64(p.total-p.n) / float64(p.n))
+		eta = remain.Round(time.Second).String()
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s] %s / %s %3.0f%% ETA %s   ", bar, fmtsize(p.n), fmtsize(p.total), 100*frac, eta)
+}
+
+// done prints the final progress line and ends it.
+func (p *progress) done() {
+	p.print(time.Now())
+	fmt.Fprintln(os.Stderr)
+}
+
+// isTerminal reports whether f appears to be a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// pruneOptions selects the installed versions that prune removes.
+type pruneOptions struct {
+	keep        int               // keep only this many newest stable versions; 0 means no limit
+	prereleases bool              // remove all betas and release candidates
+	before      toolchain.Version // remove versions older than this
+	hasBefore   bool              // whether before is set
+	dryrun      bool              // only report what would be removed
+}
+
+// prune removes installed versions selected by opt.
+// The bootstrap toolchain and the default version are never removed.
+func prune(opt *pruneOptions) error {
+	type inst struct {
+		name string
+		v    toolchain.Version
+	}
+	var stable, remove []inst
+	def := defaultVersion()
+	vers, err := installed(false)
+	if err != nil {
+		return err
+	}
+	for _, name := range vers {
+		v, err := toolchain.ParseVersion(name)
+		if err != nil || name == def {
+			// Leave cross toolchains, branches, and the default alone.
+			continue
+		}
+		switch {
+		case opt.prereleases && !v.Stable(), opt.hasBefore && v.Less(opt.before):
+			remove = append(remove, inst{name, v})
+		case v.Stable():
+			stable = append(stable, inst{name, v})
+		}
+	}
+	if opt.keep > 0 && len(stable) > opt.keep {
+		sort.Slice(stable, func(i, j int) bool { return stable[j].v.Less(stable[i].v) })
+		remove = append(remove, stable[opt.keep:]...)
+	}
+
+	parent := repoParent()
+	var total int64
+	for _, r := range remove {
+		root := filepath.Join(parent, r.name)
+		size := dirsize(root)
+		total += size
+		if opt.dryrun {
+			logEventf(logEvent{Level: "info", Version: r.name, Path: root}, "would remove %s (%s)", root, fmtsize(size))
+			continue
+		}
+		if err := os.RemoveAll(root); err != nil {
+			return fmt.Errorf("could not remove %s: %v", root, err)
+		}
+		logf("removed %s (%s)", root, fmtsize(size))
+	}
+	switch {
+	case len(remove) == 0:
+		logf("nothing to prune")
+	case opt.dryrun:
+		logf("would free %s", fmtsize(total))
+	default:
+		logf("freed %s", fmtsize(total))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GOVERSION_GIT_REMOTE, GOVERSION_DL_BASE, and GOVERSION_DL_INDEX,
+// or the git-remote, dl-base, and dl-index settings in the config file, override
+// the upstream Go repo and download URLs, for users who must go through internal mirrors.
+
+// githubRemote is GitHub's mirror of the Go repo.
+// It has the same tags and branches, but it is updated from go.googlesource.com
+// and may lag it slightly, such as just after a release is tagged.
+const githubRemote = "https://github.com/golang/go"
+
+var mirrorFromGitHub = flag.Bool("mirror-from-github", false, "clone and update the Go repo from GitHub's mirror")
+
+// gitRemote returns the URL of the Go repo.
+// -mirror-from-github selects GitHub's mirror, unless it comes from the config file
+// and GOVERSION_GIT_REMOTE is set.
+func gitRemote() (string, error) {
+	if *mirrorFromGitHub && (cmdlineFlags["mirror-from-github"] || os.Getenv("GOVERSION_GIT_REMOTE") == "") {
+		return githubRemote, nil
+	}
+	return envURL("GOVERSION_GIT_REMOTE", remote, "http", "https", "ssh", "git", "file")
+}
+
+// dlBase returns the URL that binary archives are downloaded from.
+// It always ends in a slash.
+func dlBase() (string, error) {
+	base, err := envURL("GOVERSION_DL_BASE", dlbase, "http", "https")
+	if err == nil && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base, err
+}
+
+// dlIndex returns the URL of the download index.
+func dlIndex() (string, error) {
+	return envURL("GOVERSION_DL_INDEX", dlindex, "http", "https")
+}
+
+// envURL returns the value of the environment variable key,
+// or of the config file setting it overrides, or def if neither is set.
+// It returns an error if the value is not a URL with one of the given schemes.
+func envURL(key, def string, schemes ...string) (string, error) {
+	s, source := setting(key)
+	if s == "" {
+		return def, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %v", source, err)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme && (u.Host != "" || scheme == "file") {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q: want a URL with scheme %s", source, s, strings.Join(schemes, ", "))
+}
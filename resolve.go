@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+var printResolved = flag.Bool("print", false, "print the version that would be used and where it came from, then exit")
+
+// resolve returns the version to use when none was given on the command line,
+// and a description of where it came from.
+// It looks for a .go-version file in the current directory and its parents,
+// then falls back to the default version.
+// It returns an empty ref if neither is found.
+func resolve(ctx context.Context) (ref, source string, err error) {
+	if path := findVersionFile(); path != "" {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read %s: %v", path, err)
+		}
+		s := strings.TrimSpace(string(buf))
+		ref, err := version(ctx, s)
+		if err == errNotVersion {
+			return "", "", fmt.Errorf("invalid version %q in %s", s, path)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return ref, path, nil
+	}
+	if ref := defaultVersion(); ref != "" {
+		return ref, "default", nil
+	}
+	return "", "", nil
+}
+
+// errNoVersion is returned by commands that have no version to use.
+var errNoVersion = errors.New("no version given and no .go-version file or default version found")
+
+// resolveArg is like resolve, for commands that need a version,
+// but returns errNoVersion if none is found.
+func resolveArg(ctx context.Context) (string, error) {
+	ref, _, err := resolve(ctx)
+	if err == nil && ref == "" {
+		err = errNoVersion
+	}
+	return ref, err
+}
+
+// findVersionFile returns the path of the nearest .go-version file
+// in the current directory or its parents, or "" if there is none.
+func findVersionFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return toolchain.FindVersionFile(dir)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var keepEnv = flag.Bool("keep-env", false, "run go commands with the inherited GOROOT, GOTOOLDIR, and GOTOOLCHAIN")
+
+// goEnv returns the environment in which to run the go command installed in root.
+// An inherited GOROOT or GOTOOLDIR would point the go command at another toolchain,
+// and since Go 1.21, so would GOTOOLCHAIN or a toolchain line in go.mod,
+// so GOROOT is set to root, GOTOOLDIR is removed, and GOTOOLCHAIN is set to local,
+// unless -keep-env is set.
+// The rest of the environment, such as GOPATH and GOFLAGS, is left alone.
+func goEnv(root string) []string {
+	if *keepEnv {
+		return os.Environ()
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GOROOT=") || strings.HasPrefix(kv, "GOTOOLDIR=") || strings.HasPrefix(kv, "GOTOOLCHAIN=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, "GOROOT="+root, "GOTOOLCHAIN=local")
+}
+
+// goCommand returns a command that runs the go command at path with args,
+// in the environment goEnv provides for it.
+func goCommand(path string, args ...string) *exec.Cmd {
+	cmd := exec.Command(path, args...)
+	cmd.Env = goEnv(filepath.Dir(filepath.Dir(path)))
+	return cmd
+}
+
+// runArgs splits the arguments of goversion run [<version>] -- <cmd> [<args>]
+// into the version, which may be empty, and the command line.
+// It reports false if args do not have that form.
+func runArgs(ctx context.Context, args []string) (ref string, cmdline []string, ok bool, err error) {
+	if len(args) >= 2 && args[0] == "--" {
+		return "", args[1:], true, nil
+	}
+	if len(args) >= 3 && args[1] == "--" {
+		ref, err := version(ctx, args[0])
+		if err == nil {
+			return ref, args[2:], true, nil
+		}
+		if err != errNotVersion {
+			return "", nil, false, err
+		}
+	}
+	return "", nil, false, nil
+}
+
+// run runs cmdline with the environment set up to use ref:
+// GOROOT is ref's directory and ref's bin directory is first in PATH.
+func run(ctx context.Context, ref string, cmdline []string) error {
+	path, err := ensureInstalled(ctx, ref)
+	if err != nil {
+		return err
+	}
+	// The user may have picked another version instead of ref.
+	root := filepath.Dir(filepath.Dir(path))
+	os.Setenv("PATH", filepath.Join(root, "bin")+string(filepath.ListSeparator)+os.Getenv("PATH"))
+	// exec.Command searches the new PATH, so that "go" means ref's go.
+	cmd := exec.Command(cmdline[0], cmdline[1:]...)
+	cmd.Env = goEnv(root)
+	return execute(cmd)
+}
+
+// execute runs cmd in the current directory, connected to goversion's standard streams,
+// and exits with its exit code, so that goversion <version> <args> acts like go <args>.
+// If cmd is killed by a signal, the exit code is 128 plus the signal number, as in shells,
+// where the operating system has such signals.
+// It returns only if cmd could not be run at all.
+func execute(cmd *exec.Cmd) error {
+	if cmd.Dir == "" {
+		// Run in the caller's directory explicitly rather than by inheritance.
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("could not determine current directory: %v", err)
+		}
+		cmd.Dir = wd
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if err, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitCode(err))
+		}
+		return err
+	}
+	os.Exit(0)
+	panic("unreachable")
+}
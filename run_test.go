@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGoEnv(t *testing.T) {
+	t.Setenv("GOROOT", "/elsewhere/go")
+	t.Setenv("GOTOOLDIR", "/elsewhere/go/pkg/tool/linux_amd64")
+	t.Setenv("GOTOOLCHAIN", "go1.99.0")
+	t.Setenv("GOPATH", "/gopath")
+	root := filepath.Join(t.TempDir(), "go1.21.0")
+
+	vars := map[string]string{}
+	for _, kv := range goEnv(root) {
+		k, v, _ := strings.Cut(kv, "=")
+		if _, dup := vars[k]; dup {
+			t.Errorf("goEnv sets %s twice", k)
+		}
+		vars[k] = v
+	}
+	if vars["GOROOT"] != root {
+		t.Errorf("GOROOT=%q; want %q", vars["GOROOT"], root)
+	}
+	if v, ok := vars["GOTOOLDIR"]; ok {
+		t.Errorf("GOTOOLDIR=%q; want unset", v)
+	}
+	if vars["GOTOOLCHAIN"] != "local" {
+		t.Errorf("GOTOOLCHAIN=%q; want local", vars["GOTOOLCHAIN"])
+	}
+	if vars["GOPATH"] != "/gopath" {
+		t.Errorf("GOPATH=%q; want it inherited", vars["GOPATH"])
+	}
+
+	defer func(keep bool) { *keepEnv = keep }(*keepEnv)
+	*keepEnv = true
+	if env := goEnv(root); !reflect.DeepEqual(env, os.Environ()) {
+		t.Errorf("with -keep-env, goEnv changed the environment")
+	}
+}
+
+// TestGoCommand runs a stand-in go command to check the environment it sees.
+func TestGoCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in go command is a shell script")
+	}
+	t.Setenv("GOROOT", "/elsewhere/go")
+	t.Setenv("GOTOOLCHAIN", "auto")
+	root := filepath.Join(t.TempDir(), "go1.21.0")
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "bin", "go")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"$GOROOT $GOTOOLCHAIN $*\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := goCommand(path, "version").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(out)), root+" local version"; got != want {
+		t.Errorf("go command saw %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// releasesURL is the GitHub API endpoint describing the latest release of goversion.
+// GOVERSION_RELEASES_URL overrides it.
+const releasesURL = "https://api.github.com/repos/josharian/goversion/releases/latest"
+
+// A release is the subset of a GitHub release that self-update needs.
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// asset returns the download URL of the release asset called name, or "".
+func (r *release) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// selfUpdate replaces the running goversion with the latest release.
+// Release assets are named goversion-<goos>-<goarch>, with .exe on Windows,
+// and each has a .sha256 file alongside.
+func selfUpdate(ctx context.Context) error {
+	url, err := envURL("GOVERSION_RELEASES_URL", releasesURL, "http", "https")
+	if err != nil {
+		return err
+	}
+	resp, err := get(ctx, url)
+	if err != nil {
+		return fmt.Errorf("could not check for updates: %v", err)
+	}
+	var rel release
+	err = json.NewDecoder(resp.Body).Decode(&rel)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %v", url, err)
+	}
+	vers, _, _ := toolVersion()
+	if rel.Tag == vers {
+		logf("goversion %s is up to date", vers)
+		return nil
+	}
+	name := "goversion-" + runtime.GOOS + "-" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	binURL := rel.asset(name)
+	if binURL == "" {
+		return fmt.Errorf("goversion %s has no binary for %s", rel.Tag, host())
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find the goversion executable: %v", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("could not find the goversion executable: %v", err)
+	}
+	// Windows can't remove a running executable,
+	// so the previous update left it behind for us to clean up now.
+	old := exe + ".old"
+	os.Remove(old)
+
+	// Download next to exe so that the final rename stays on one filesystem.
+	logf("downloading %s", binURL)
+	resp, err = getStream(ctx, binURL, nil)
+	if err != nil {
+		return fmt.Errorf("could not download %s: %v", binURL, err)
+	}
+	defer resp.Body.Close()
+	f, err := os.CreateTemp(filepath.Dir(exe), filepath.Base(exe)+".*.new")
+	if err != nil {
+		return fmt.Errorf("could not create temp file: %v", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("could not download %s: %v", binURL, err)
+	}
+	if !*skipVerify {
+		sumURL := rel.asset(name + ".sha256")
+		if sumURL == "" {
+			return fmt.Errorf("could not verify %s: no checksum published", binURL)
+		}
+		want, err := checksum(ctx, strings.TrimSuffix(sumURL, ".sha256"))
+		if err != nil {
+			return fmt.Errorf("could not verify %s: %v", binURL, err)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", binURL, want, got)
+		}
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		// A running executable can be renamed but not replaced.
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("could not replace %s: %v", exe, err)
+		}
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		if runtime.GOOS == "windows" {
+			os.Rename(old, exe)
+		}
+		return fmt.Errorf("could not replace %s: %v", exe, err)
+	}
+	fmt.Printf("updated goversion from %s to %s\n", vers, rel.Tag)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var minFree = flag.Int("min-free", -1, "MiB of free disk space to require before installing; 0 skips the check")
+
+// Conservative estimates of the disk space an install needs.
+const (
+	binaryNeed = 500 << 20 // an unpacked release
+	sourceNeed = 800 << 20 // a source tree and its build output
+)
+
+// checkSpace returns an error if the filesystem holding the installed versions
+// has less than need bytes free, or less than -min-free if it was given.
+// Systems where free space can't be determined are not checked.
+func checkSpace(need int64) error {
+	if *minFree >= 0 {
+		need = int64(*minFree) << 20
+	}
+	if need == 0 {
+		return nil
+	}
+	// The root may not exist yet; check the nearest directory that does.
+	dir := repoParent()
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	free, err := diskFree(dir)
+	if err != nil {
+		vlogf("could not determine free space in %s: %v", dir, err)
+		return nil
+	}
+	if free < need {
+		return fmt.Errorf("not enough disk space in %s: %s free, need %s; use -min-free to override", dir, fmtsize(free), fmtsize(need))
+	}
+	return nil
+}
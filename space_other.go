@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!windows
+
+package main
+
+import "errors"
+
+// diskFree is not implemented on this system.
+func diskFree(dir string) (int64, error) {
+	return 0, errors.New("not supported")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// tipName is the directory that the master branch is installed in.
+const tipName = "tip"
+
+// tip rebuilds the master branch of the Go repo and installs it as tip.
+// Unless noUpdate is set, it first fetches the latest master from upstream.
+func tip(ctx context.Context, noUpdate bool) error {
+	if noUpdate || *offline {
+		// Build whatever master the mirror already has.
+		updated = true
+	} else {
+		if err := update(ctx); err != nil {
+			return err
+		}
+		if err := fetchTip(ctx); err != nil {
+			return fmt.Errorf("could not fetch master: %v", err)
+		}
+	}
+	return install(ctx, "master", &installOptions{source: true, force: true, name: installName(tipName, host())})
+}
+
+// fetchTip updates the master branch in the Go mirror.
+// A plain git fetch in a bare repo only updates FETCH_HEAD.
+func fetchTip(ctx context.Context) error {
+	mirror := filepath.Join(repoParent(), "go.mirror")
+	remote, err := gitRemote()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "fetch", remote, "+refs/heads/master:refs/heads/master")
+	if isShallow(mirror) {
+		cmd.Args = append(cmd.Args, "--depth", "1")
+	}
+	if *quiet || *logJSON {
+		cmd.Args = append(cmd.Args, "--quiet")
+	}
+	cmd.Dir = mirror
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logf("fetching master")
+	return cmd.Run()
+}
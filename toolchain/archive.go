@@ -0,0 +1,57 @@
+package toolchain
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+)
+
+// ErrNoBinary reports that a version has no binary archive for a platform.
+var ErrNoBinary = errors.New("binary not available")
+
+// Archives returns the file names that a binary archive of version
+// for goos/goarch may have in the download index, in order of preference.
+func Archives(version, goos, goarch string) []string {
+	suffixes := []string{".tar.gz"}
+	switch goos {
+	case "windows":
+		suffixes = []string{".zip"}
+	case "darwin":
+		// Modern releases are plain tarballs, such as go1.21.0.darwin-arm64.tar.gz.
+		// Older ones were built per OS X release, such as go1.4.darwin-amd64-osx10.8.tar.gz,
+		// and some releases only shipped an installer package.
+		suffixes = []string{".tar.gz", "-osx10.8.tar.gz", "-osx10.6.tar.gz"}
+		// Expanding a package needs pkgutil, which only macOS has.
+		if runtime.GOOS == "darwin" {
+			suffixes = append(suffixes, ".pkg", "-osx10.8.pkg", "-osx10.6.pkg")
+		}
+	}
+	// The downloads name architectures by GOARCH, except for 32-bit ARM:
+	// there is one build, for ARMv6 (which also runs on ARMv7), named armv6l.
+	// This undoes the mapping in the goversion command's parseDlFile.
+	arch := goarch
+	if goarch == "arm" {
+		arch = "armv6l"
+	}
+	var files []string
+	for _, suffix := range suffixes {
+		files = append(files, version+"."+goos+"-"+arch+suffix)
+	}
+	return files
+}
+
+// SelectArchive returns the URL under base of the binary archive of version for goos/goarch,
+// given the URLs listed in the download index, or ErrNoBinary if none is listed.
+// It matches on file name, so that it doesn't matter which host the index lists.
+func SelectArchive(index []string, base, version, goos, goarch string) (string, error) {
+	listed := map[string]bool{}
+	for _, url := range index {
+		listed[url[strings.LastIndexByte(url, '/')+1:]] = true
+	}
+	for _, file := range Archives(version, goos, goarch) {
+		if listed[file] {
+			return base + file, nil
+		}
+	}
+	return "", ErrNoBinary
+}
@@ -0,0 +1,58 @@
+package toolchain
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestArchives(t *testing.T) {
+	tests := []struct {
+		version, goos, goarch string
+		want                  []string
+	}{
+		{"go1.21.0", "linux", "amd64", []string{"go1.21.0.linux-amd64.tar.gz"}},
+		{"go1.21.0", "linux", "arm", []string{"go1.21.0.linux-armv6l.tar.gz"}},
+		{"go1.21.0", "linux", "arm64", []string{"go1.21.0.linux-arm64.tar.gz"}},
+		{"go1.21.0", "freebsd", "arm", []string{"go1.21.0.freebsd-armv6l.tar.gz"}},
+		{"go1.21.0", "windows", "arm64", []string{"go1.21.0.windows-arm64.zip"}},
+		{"go1.21.0", "windows", "386", []string{"go1.21.0.windows-386.zip"}},
+	}
+	for _, tt := range tests {
+		if got := Archives(tt.version, tt.goos, tt.goarch); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Archives(%s, %s, %s) = %q; want %q", tt.version, tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+
+	want := []string{
+		"go1.16.darwin-arm64.tar.gz",
+		"go1.16.darwin-arm64-osx10.8.tar.gz",
+		"go1.16.darwin-arm64-osx10.6.tar.gz",
+	}
+	if runtime.GOOS == "darwin" {
+		want = append(want,
+			"go1.16.darwin-arm64.pkg",
+			"go1.16.darwin-arm64-osx10.8.pkg",
+			"go1.16.darwin-arm64-osx10.6.pkg",
+		)
+	}
+	if got := Archives("go1.16", "darwin", "arm64"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Archives(go1.16, darwin, arm64) = %q; want %q", got, want)
+	}
+}
+
+func TestSelectArchive(t *testing.T) {
+	index := []string{
+		"https://dl.google.com/go/go1.21.0.linux-amd64.tar.gz",
+		"https://dl.google.com/go/go1.21.0.linux-armv6l.tar.gz",
+		"https://dl.google.com/go/go1.21.0.src.tar.gz",
+	}
+	const base = "https://mirror.example/go/"
+	url, err := SelectArchive(index, base, "go1.21.0", "linux", "arm")
+	if want := base + "go1.21.0.linux-armv6l.tar.gz"; url != want || err != nil {
+		t.Errorf("SelectArchive(go1.21.0, linux, arm) = %q, %v; want %q, nil", url, err, want)
+	}
+	if url, err := SelectArchive(index, base, "go1.21.0", "linux", "arm64"); err != ErrNoBinary {
+		t.Errorf("SelectArchive(go1.21.0, linux, arm64) = %q, %v; want ErrNoBinary", url, err)
+	}
+}
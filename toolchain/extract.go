@@ -0,0 +1,264 @@
+package toolchain
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Extract extracts the Go distribution in the archive at path into root,
+// creating directories as needed and preserving file modes.
+// The archive may be a .tar.gz, .tgz, or .zip file, or, on macOS, a .pkg installer package.
+// Official archives hold everything under a top-level go/ directory,
+// which is stripped.
+func Extract(path, root string) error {
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return untargz(path, root)
+	case strings.HasSuffix(path, ".zip"):
+		return unzip(path, root)
+	case strings.HasSuffix(path, ".pkg"):
+		return unpkg(path, root)
+	}
+	return fmt.Errorf("unrecognized archive type: %s", path)
+}
+
+// Unpack extracts the Go distribution in the archive at path, as Extract does,
+// into a new directory in root, and returns the directory's path.
+// The directory's name starts with .tarball, so that until the caller
+// renames it into place, the partial toolchain never looks installed,
+// and the rename stays on one filesystem.
+// The caller must remove the directory if it does not rename it.
+func Unpack(path, root string) (string, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(root, ".tarball")
+	if err != nil {
+		return "", err
+	}
+	// MkdirTemp makes private directories; toolchains are for everyone.
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	if err := Extract(path, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("could not unpack %s: %v", path, err)
+	}
+	return dir, nil
+}
+
+// Untar extracts the uncompressed tar stream r into root.
+// Every entry must be under prefix, such as "go/", which is stripped;
+// an empty prefix accepts every entry as is.
+// Entries other than directories, regular files, and symlinks are ignored.
+func Untar(r io.Reader, root, prefix string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA, tar.TypeSymlink:
+		default:
+			continue
+		}
+		name, ok := strip(hdr.Name, prefix)
+		if !ok {
+			return fmt.Errorf("unexpected archive entry %s", hdr.Name)
+		}
+		outpath, err := entryPath(root, name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(outpath, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeFile(outpath, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := writeSymlink(root, outpath, hdr.Linkname); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// strip removes prefix from the archive entry name.
+// It reports false for entries outside of prefix.
+func strip(name, prefix string) (string, bool) {
+	name = filepath.ToSlash(name)
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, prefix), true
+}
+
+// entryPath returns the path in root at which to extract the archive entry name.
+// It rejects entries that would land outside root, such as ../../evil,
+// and entries under a symlink extracted earlier, since the check is lexical
+// and a chain of symlinks such as d/l -> .. and d/l/m -> .. can lead anywhere.
+// root is absolute, so package os uses extended-length paths for deep entries on Windows.
+func entryPath(root, name string) (string, error) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if !within(root, path) {
+		return "", fmt.Errorf("archive entry %s is outside the archive", name)
+	}
+	for dir := filepath.Dir(path); within(root, dir) && dir != root; dir = filepath.Dir(dir) {
+		if fi, err := os.Lstat(dir); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %s is under the symlink %s", name, dir)
+		}
+	}
+	return path, nil
+}
+
+// within reports whether path is root or inside it.
+// Both must be clean.
+func within(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// writeFile writes the contents of r to path with mode perm,
+// creating parent directories as needed.
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Replace rather than write through a symlink of the same name.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// OpenFile honors the umask; make sure executable bits survive.
+	return os.Chmod(path, perm)
+}
+
+// untargz extracts a .tar.gz archive into root.
+func untargz(path, root string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	return Untar(zr, root, "go/")
+}
+
+// writeSymlink creates a symlink at path in root pointing to target,
+// creating parent directories as needed.
+// Targets outside root are rejected.
+func writeSymlink(root, path, target string) error {
+	if filepath.IsAbs(target) || !within(root, filepath.Join(filepath.Dir(path), target)) {
+		return fmt.Errorf("symlink %s points outside the archive to %s", path, target)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	os.Remove(path)
+	err := os.Symlink(target, path)
+	if err != nil && runtime.GOOS == "windows" {
+		// Creating symlinks needs special privileges on Windows.
+		// Toolchains don't depend on them, so carry on without.
+		return nil
+	}
+	return err
+}
+
+// unzip extracts a .zip archive into root.
+func unzip(path, root string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		name, ok := strip(f.Name, "go/")
+		if !ok {
+			return fmt.Errorf("unexpected archive entry %s", f.Name)
+		}
+		outpath, err := entryPath(root, name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(outpath, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			// The contents of a symlink entry are its target.
+			var target []byte
+			target, err = io.ReadAll(rc)
+			if err == nil {
+				err = writeSymlink(root, outpath, string(target))
+			}
+		} else {
+			err = writeFile(outpath, rc, f.Mode().Perm())
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unpkg extracts the toolchain from a macOS installer package into root.
+// It uses pkgutil to expand the package rather than running the installer.
+func unpkg(path, root string) error {
+	tmp, err := os.MkdirTemp(filepath.Dir(root), ".pkg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	exp := filepath.Join(tmp, "pkg")
+	cmd := exec.Command("pkgutil", "--expand-full", path, exp)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("pkgutil: %v\n\n%s", err, out)
+	}
+	// The package installs into /usr/local/go.
+	matches, err := filepath.Glob(filepath.Join(exp, "*", "Payload", "usr", "local", "go"))
+	if err != nil {
+		return err
+	}
+	if len(matches) != 1 {
+		return fmt.Errorf("could not find toolchain in %s", path)
+	}
+	return os.Rename(matches[0], root)
+}
@@ -0,0 +1,164 @@
+package toolchain
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// tarEntry describes one entry of a synthetic archive.
+type tarEntry struct {
+	name string
+	link string // symlink target, for symlinks
+	body string
+	mode int64
+}
+
+func makeTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case e.name[len(e.name)-1] == '/':
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestUntarEscape(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{"dotdot", []tarEntry{
+			{name: "go/../../evil", body: "x", mode: 0644},
+		}},
+		{"absolute symlink", []tarEntry{
+			{name: "go/l", link: "/"},
+			{name: "go/l/evil", body: "x", mode: 0644},
+		}},
+		{"chained symlinks", []tarEntry{
+			{name: "go/d/", mode: 0755},
+			{name: "go/d/l", link: ".."},
+			{name: "go/d/l/m", link: ".."},
+			{name: "go/d/l/m/evil", body: "x", mode: 0644},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "a", "b")
+			root := filepath.Join(dir, "root")
+			if err := os.MkdirAll(root, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := Untar(makeTar(t, tt.entries), root, "go/"); err == nil {
+				t.Errorf("Untar succeeded; want error")
+			}
+			for _, d := range []string{dir, filepath.Dir(dir), filepath.Dir(filepath.Dir(dir))} {
+				if _, err := os.Lstat(filepath.Join(d, "evil")); err == nil {
+					t.Errorf("Untar wrote %s", filepath.Join(d, "evil"))
+				}
+			}
+		})
+	}
+}
+
+// distEntries are the entries of a tiny Go distribution,
+// with an executable and a symlink.
+var distEntries = []tarEntry{
+	{name: "go/", mode: 0755},
+	{name: "go/VERSION", body: "go1.21.0\n", mode: 0644},
+	{name: "go/bin/go", body: "#!/bin/sh\n", mode: 0755},
+	{name: "go/misc/go", link: "../bin/go"},
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+
+	tgz := filepath.Join(dir, "go1.21.0.linux-amd64.tar.gz")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := makeTar(t, distEntries).WriteTo(zw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tgz, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zipfile := filepath.Join(dir, "go1.21.0.windows-amd64.zip")
+	buf.Reset()
+	w := zip.NewWriter(&buf)
+	for _, e := range distEntries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		if e.link != "" {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.link
+		} else {
+			hdr.SetMode(os.FileMode(e.mode))
+		}
+		f, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(zipfile, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, archive := range []string{tgz, zipfile} {
+		t.Run(filepath.Ext(archive), func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "go1.21.0")
+			if err := Extract(archive, root); err != nil {
+				t.Fatal(err)
+			}
+			buf, err := os.ReadFile(filepath.Join(root, "VERSION"))
+			if err != nil || string(buf) != "go1.21.0\n" {
+				t.Errorf("VERSION = %q, %v; want %q", buf, err, "go1.21.0\n")
+			}
+			if runtime.GOOS == "windows" {
+				// Windows has no executable bits, and symlinks need privileges.
+				return
+			}
+			fi, err := os.Stat(filepath.Join(root, "bin", "go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != 0755 {
+				t.Errorf("bin/go has mode %v; want %v", fi.Mode().Perm(), os.FileMode(0755))
+			}
+			target, err := os.Readlink(filepath.Join(root, "misc", "go"))
+			if err != nil || target != "../bin/go" {
+				t.Errorf("misc/go links to %q, %v; want %q", target, err, "../bin/go")
+			}
+		})
+	}
+}
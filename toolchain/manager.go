@@ -0,0 +1,310 @@
+// Package toolchain manages Go toolchains laid out the way the goversion command lays them out:
+// each version in a directory named for it under a root, such as root/go1.21.0,
+// with an optional default version.
+//
+// It lets editors and build tools list, install, and find toolchains
+// without running goversion. The goversion command builds on it,
+// adding source builds, retries, progress reporting, and locking.
+package toolchain
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Default locations of upstream Go.
+const (
+	DefaultRemote = "https://go.googlesource.com/go"
+	DefaultIndex  = "https://storage.googleapis.com/go-builder-data/dl-index.txt"
+	DefaultBase   = "https://storage.googleapis.com/golang/"
+)
+
+// DefaultName is the name of the entry in the root that records the default version:
+// a symlink to the version's directory, or on Windows, a file containing the version.
+const DefaultName = "current"
+
+// VersionFile is the name of the file that pins the Go version for a directory tree.
+const VersionFile = ".go-version"
+
+// A Manager manages the toolchains installed in a root directory.
+// The zero value of each optional field means its default.
+type Manager struct {
+	Root   string       // directory holding installed versions
+	Client *http.Client // client for downloads; nil means http.DefaultClient
+	Remote string       // URL of the Go repo; empty means DefaultRemote
+	Index  string       // URL of the download index; empty means DefaultIndex
+	Base   string       // URL prefix that archives are downloaded from; empty means DefaultBase
+}
+
+// A NotInstalledError reports that a version is not installed.
+type NotInstalledError struct {
+	Version string
+}
+
+func (e *NotInstalledError) Error() string {
+	return e.Version + " is not installed"
+}
+
+// GoCommand returns the path of the go command of the toolchain in root,
+// and whether it exists. Either bin/go or bin/go.exe counts,
+// so that cross toolchains for and from Windows are found too;
+// the one for the running platform is preferred.
+func GoCommand(root string) (path string, ok bool) {
+	names := []string{"go", "go.exe"}
+	if runtime.GOOS == "windows" {
+		names[0], names[1] = names[1], names[0]
+	}
+	for _, name := range names {
+		path := filepath.Join(root, "bin", name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return filepath.Join(root, "bin", names[0]), false
+}
+
+// Which returns the path of the go command of the installed version.
+// If version is not installed, the error is a *NotInstalledError.
+func (m *Manager) Which(version string) (string, error) {
+	path, ok := GoCommand(filepath.Join(m.Root, version))
+	if !ok {
+		return "", &NotInstalledError{version}
+	}
+	return path, nil
+}
+
+// Installed returns the installed versions, sorted oldest first as by TagLess.
+// Besides releases, they may include branches and other refs built from source.
+func (m *Manager) Installed() ([]string, error) {
+	fis, err := os.ReadDir(m.Root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", m.Root, err)
+	}
+	var vers []string
+	for _, fi := range fis {
+		name := fi.Name()
+		if !fi.IsDir() || name == "go.mirror" {
+			continue
+		}
+		if _, err := m.Which(name); err != nil {
+			continue
+		}
+		vers = append(vers, name)
+	}
+	sort.Slice(vers, func(i, j int) bool { return TagLess(vers[i], vers[j]) })
+	return vers, nil
+}
+
+// Default returns the default version, or "" if none has been set.
+func (m *Manager) Default() string {
+	link := filepath.Join(m.Root, DefaultName)
+	if runtime.GOOS == "windows" {
+		buf, err := os.ReadFile(link)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(buf))
+	}
+	target, err := os.Readlink(link)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// FindVersionFile returns the path of the nearest .go-version file
+// in dir or its parents, or "" if there is none.
+func FindVersionFile(dir string) string {
+	for {
+		path := filepath.Join(dir, VersionFile)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+		up := filepath.Dir(dir)
+		if up == dir {
+			return ""
+		}
+		dir = up
+	}
+}
+
+// Resolve returns the version to use in dir, and a description of where it came from:
+// the path of the nearest .go-version file, or "default" for the default version.
+// It returns an empty version if neither is found.
+func (m *Manager) Resolve(dir string) (version, source string, err error) {
+	if path := FindVersionFile(dir); path != "" {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read %s: %v", path, err)
+		}
+		s := strings.TrimSpace(string(buf))
+		version, err := Normalize(s)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid version %q in %s", s, path)
+		}
+		return version, path, nil
+	}
+	if version := m.Default(); version != "" {
+		return version, "default", nil
+	}
+	return "", "", nil
+}
+
+// List returns the tagged releases in the Go repo, sorted oldest first.
+// It needs git.
+func (m *Manager) List(ctx context.Context) ([]string, error) {
+	remote := m.Remote
+	if remote == "" {
+		remote = DefaultRemote
+	}
+	out, err := exec.CommandContext(ctx, "git", "ls-remote", "--tags", remote, "go1*").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("could not list tags: %v\n\n%s", err, out)
+	}
+	var tags []string
+	scan := bufio.NewScanner(bytes.NewReader(out))
+	for scan.Scan() {
+		line := scan.Text()
+		ff := strings.Fields(line)
+		if len(ff) != 2 {
+			return nil, fmt.Errorf("unexpected git ls-remote line %q", line)
+		}
+		// Skip the peeled commits of annotated tags.
+		if strings.HasSuffix(ff[1], "^{}") {
+			continue
+		}
+		tags = append(tags, strings.TrimPrefix(ff[1], "refs/tags/"))
+	}
+	sort.Slice(tags, func(i, j int) bool { return TagLess(tags[i], tags[j]) })
+	return tags, nil
+}
+
+// Install installs the official binary distribution of version for the running platform,
+// verifying its published checksum.
+// It does nothing if version is already installed.
+// It returns ErrNoBinary if there is no binary distribution to install;
+// such versions must be built from source, which the goversion command can do.
+func (m *Manager) Install(ctx context.Context, version string) error {
+	if _, err := m.Which(version); err == nil {
+		return nil
+	}
+	index := m.Index
+	if index == "" {
+		index = DefaultIndex
+	}
+	body, err := m.get(ctx, index)
+	if err != nil {
+		return fmt.Errorf("could not fetch download index: %v", err)
+	}
+	base := m.Base
+	if base == "" {
+		base = DefaultBase
+	}
+	url, err := SelectArchive(strings.Fields(string(body)), base, version, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	// Download into the root, where only this root's cleanup will look for leftovers.
+	if err := os.MkdirAll(m.Root, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(m.Root, ".download")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, url[strings.LastIndexByte(url, '/')+1:])
+	sum, err := m.download(ctx, url, path)
+	if err != nil {
+		return fmt.Errorf("could not download %s: %v", url, err)
+	}
+	want, err := m.get(ctx, url+".sha256")
+	if err != nil {
+		return fmt.Errorf("could not verify %s: %v", url, err)
+	}
+	if ff := strings.Fields(string(want)); len(ff) == 0 || strings.ToLower(ff[0]) != sum {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, bytes.TrimSpace(want), sum)
+	}
+
+	tmp, err := Unpack(path, m.Root)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.WriteFile(filepath.Join(tmp, "origin"), []byte("sha256 "+sum+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write origin: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(m.Root, version)); err != nil {
+		return fmt.Errorf("could not install %s: %v", version, err)
+	}
+	return nil
+}
+
+// do sends a GET request for url, failing on a non-2xx status.
+func (m *Manager) do(ctx context.Context, url string) (*http.Response, error) {
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// get returns the body of url.
+func (m *Manager) get(ctx context.Context, url string) ([]byte, error) {
+	resp, err := m.do(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// download writes the body of url to path and returns its SHA256 digest in hex.
+func (m *Manager) download(ctx context.Context, url, path string) (string, error) {
+	resp, err := m.do(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,110 @@
+package toolchain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Version is a parsed Go release version.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string // prerelease suffix, such as beta1 or rc2; empty for final releases
+}
+
+// versionRE matches release tags such as go1, go1.8, go1.7.4, and go1.8beta1.
+var versionRE = regexp.MustCompile(`^go(\d+)(?:\.(\d+))?(?:\.(\d+))?((?:beta|rc)\d+)?$`)
+
+// ParseVersion parses a release tag such as go1.8, go1.7.4, or go1.8beta1.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	m := versionRE.FindStringSubmatch(s)
+	if m == nil {
+		return v, fmt.Errorf("malformed version %q", s)
+	}
+	for i, dst := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] != "" {
+			*dst, _ = strconv.Atoi(m[i+1])
+		}
+	}
+	v.Pre = m[4]
+	return v, nil
+}
+
+// Stable reports whether v is a final release rather than a beta or release candidate.
+func (v Version) Stable() bool {
+	return v.Pre == ""
+}
+
+// Less reports whether v is older than w.
+// Prereleases sort before the corresponding final release,
+// and betas sort before release candidates.
+func (v Version) Less(w Version) bool {
+	if v.Major != w.Major {
+		return v.Major < w.Major
+	}
+	if v.Minor != w.Minor {
+		return v.Minor < w.Minor
+	}
+	if v.Patch != w.Patch {
+		return v.Patch < w.Patch
+	}
+	if v.Pre == "" || w.Pre == "" {
+		return v.Pre != "" && w.Pre == ""
+	}
+	vb, wb := strings.HasPrefix(v.Pre, "beta"), strings.HasPrefix(w.Pre, "beta")
+	if vb != wb {
+		return vb
+	}
+	return preNum(v.Pre) < preNum(w.Pre)
+}
+
+// preNum returns the number at the end of a prerelease suffix such as rc2.
+func preNum(pre string) int {
+	n, _ := strconv.Atoi(strings.TrimLeft(pre, "abcdefghijklmnopqrstuvwxyz"))
+	return n
+}
+
+// TagLess reports whether tag a sorts before tag b.
+// Tags that are not releases sort last, alphabetically.
+func TagLess(a, b string) bool {
+	va, erra := ParseVersion(a)
+	vb, errb := ParseVersion(b)
+	switch {
+	case erra == nil && errb == nil:
+		return va.Less(vb)
+	case (erra == nil) != (errb == nil):
+		return erra == nil
+	}
+	return a < b
+}
+
+// ErrNotVersion is returned by Normalize for strings that don't look like Go versions.
+var ErrNotVersion = errors.New("not a Go version")
+
+// Normalize converts versions to have a go prefix.
+// For example, go1.7.4 and 1.7.4 both return go1.7.4.
+// Versions must have the form 1.N or 1.N.P, optionally followed by betaK or rcK;
+// Normalize returns ErrNotVersion for anything else.
+func Normalize(s string) (string, error) {
+	// Accept both go1.7.4 and 1.7.4.
+	s = "go" + strings.TrimPrefix(s, "go")
+	v, err := ParseVersion(s)
+	if err != nil || v.Major != 1 || !strings.Contains(s, ".") {
+		return "", ErrNotVersion
+	}
+	// The first release of Go 1.N is tagged go1.N.0 starting with Go 1.21,
+	// and go1.N before that. Accept either spelling for both,
+	// so that 1.21 means go1.21.0 and 1.20.0 means go1.20.
+	if v.Pre == "" && v.Minor > 0 {
+		switch {
+		case v.Minor >= 21:
+			s = fmt.Sprintf("go1.%d.%d", v.Minor, v.Patch)
+		case v.Patch == 0:
+			s = fmt.Sprintf("go1.%d", v.Minor)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,75 @@
+package toolchain
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string // want is "" for ErrNotVersion
+	}{
+		{"1.8beta1", "go1.8beta1"},
+		{"go1.8beta1", "go1.8beta1"},
+		{"1.7.4", "go1.7.4"},
+		{"go1.20.3", "go1.20.3"},
+		{"1.20.0", "go1.20"},
+		{"1.21", "go1.21.0"},
+		{"1.21rc2", "go1.21rc2"},
+		{"1", ""},
+		{"go1", ""},
+		{"1.", ""},
+		{"1.8.0.0", ""},
+		{"2.0", ""},
+		{"", ""},
+		{"go", ""},
+		{"tip", ""},
+		{"1.8x", ""},
+		{"release-branch.go1.21", ""},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if tt.want == "" {
+			if err != ErrNotVersion {
+				t.Errorf("Normalize(%q) = %q, %v; want ErrNotVersion", tt.in, got, err)
+			}
+			continue
+		}
+		if got != tt.want || err != nil {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLess(t *testing.T) {
+	// Each tag sorts before the next.
+	tags := []string{
+		"go1",
+		"go1.8beta1",
+		"go1.8rc1",
+		"go1.8rc2",
+		"go1.8",
+		"go1.8.1",
+		"go1.9",
+		"go1.10",
+		"go1.21.0",
+		"go1.21.2",
+		"go1.21.10",
+		"go1.22rc1",
+		"go1.22.0",
+		"release-branch.go1.4",
+		"tip",
+	}
+	for i, a := range tags {
+		for j, b := range tags {
+			if got, want := TagLess(a, b), i < j; got != want {
+				t.Errorf("TagLess(%s, %s) = %v; want %v", a, b, got, want)
+			}
+			va, erra := ParseVersion(a)
+			vb, errb := ParseVersion(b)
+			if erra != nil || errb != nil {
+				continue
+			}
+			if got, want := va.Less(vb), i < j; got != want {
+				t.Errorf("%s.Less(%s) = %v; want %v", a, b, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// uninstallArg returns the installed version that the uninstall argument s names.
+// Anything installed can be named by its directory, as listed by installed -all,
+// including tip, -ref builds by their ref, cross toolchains, and GOARM or GOAMD64 variants,
+// since those are not Go versions.
+// Otherwise s must be a Go version or alias.
+func uninstallArg(ctx context.Context, s string) (string, error) {
+	vers, err := installed(true)
+	if err != nil {
+		return "", err
+	}
+	name, _ := refName(s)
+	for _, v := range vers {
+		if v == s || v == name {
+			return v, nil
+		}
+	}
+	return versionArg(ctx, s)
+}
+
+// uninstall removes the installed version ref.
+// Like prune, it refuses to remove the bootstrap toolchain or the default version
+// unless force is set; removing the default also unsets it.
+// If dryrun is set, it only reports what would be removed.
+func uninstall(ref string, force, dryrun bool) error {
+	if ref == release14 && !force {
+		return fmt.Errorf("%s is needed to bootstrap source builds; use -force to remove it anyway", ref)
+	}
+	isDefault := ref == defaultVersion()
+	if isDefault && !force {
+		return fmt.Errorf("%s is the default version; set another default first, or use -force to remove it anyway", ref)
+	}
+	root := filepath.Join(repoParent(), ref)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return fmt.Errorf("%s is not installed", ref)
+	}
+	size := dirsize(root)
+	if dryrun {
+		logEventf(logEvent{Level: "info", Version: ref, Path: root}, "would remove %s (%s)", root, fmtsize(size))
+		return nil
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return fmt.Errorf("could not remove %s: %v", root, err)
+	}
+	logf("removed %s, freed %s", root, fmtsize(size))
+	if isDefault {
+		if err := os.Remove(filepath.Join(repoParent(), current)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not unset default: %v", err)
+		}
+		logf("%s was the default; no default is set now", ref)
+	}
+	return nil
+}
+
+// dirsize returns the total size in bytes of the regular files under root.
+func dirsize(root string) int64 {
+	var n int64
+	filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			n += fi.Size()
+		}
+		return nil
+	})
+	return n
+}
+
+// fmtsize formats n bytes for humans.
+func fmtsize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
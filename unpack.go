@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// unpack extracts the binary archive of ref at path into the directory name.
+// The archive is left in place for the caller to remove.
+// The toolchain appears under name only once it is complete.
+func unpack(ref, name, path string) error {
+	parent := repoParent()
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := toolchain.Unpack(path, parent)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := writeVersion(tmp, ref); err != nil {
+		return err
+	}
+	if err := writeOrigin(tmp, "sha256", sum); err != nil {
+		return err
+	}
+	if _, exist := cmdgo(parent, filepath.Base(tmp)); !exist {
+		return fmt.Errorf("could not find cmd/go in %s", path)
+	}
+	if err := os.Rename(tmp, filepath.Join(parent, name)); err != nil {
+		return fmt.Errorf("could not install %s: %v", name, err)
+	}
+	return nil
+}
+
+// printTarballPlan prints what installTarball would do with the same arguments,
+// without unpacking the archive.
+func printTarballPlan(path, want, pin string, force bool) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	parent := repoParent()
+	fmt.Printf("%s:\n", path)
+	if pin != "" {
+		fmt.Printf("\tcheck that it has sha256 %s\n", pin)
+	}
+	if want == "" {
+		fmt.Printf("\tunpack into %s under the version in its VERSION file (needs about %s free)\n", parent, fmtsize(binaryNeed))
+		return nil
+	}
+	root := filepath.Join(parent, want)
+	if _, err := os.Stat(root); err == nil {
+		if !force {
+			fmt.Printf("\talready installed in %s\n", root)
+			return nil
+		}
+		fmt.Printf("\tremove %s\n", root)
+	}
+	fmt.Printf("\tunpack into %s (needs about %s free)\n", root, fmtsize(binaryNeed))
+	return nil
+}
+
+// installTarball installs the Go distribution in the local archive at path,
+// under the version named by its VERSION file.
+// If want is non-empty, the archive must contain that version,
+// and with -sha256, the archive must have the pinned digest.
+// It uses neither the network nor the Go repo.
+func installTarball(ctx context.Context, path, want string, opt *installOptions) (err error) {
+	var ref string
+	if opt.as != "" {
+		defer func() {
+			if err == nil {
+				err = alias(ctx, opt.as, ref)
+			}
+		}()
+	}
+	pin, force := opt.sha256, opt.force
+	rep := opt.report
+	if rep == nil {
+		rep = &installReport{Version: want}
+	}
+	parent := repoParent()
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if pin != "" && sum != pin {
+		return fmt.Errorf("checksum mismatch for %s: pinned %s, got %s", path, pin, sum)
+	}
+	start := time.Now()
+	tmp, err := toolchain.Unpack(path, parent)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	line := treeVersion(tmp)
+	if line == "" {
+		return fmt.Errorf("%s is not a Go distribution: no go/VERSION", path)
+	}
+	if fi, err := os.Stat(filepath.Join(tmp, "bin")); err != nil || !fi.IsDir() {
+		return fmt.Errorf("%s is not a Go distribution: no go/bin", path)
+	}
+	ref, err = version(ctx, line)
+	if err != nil {
+		return fmt.Errorf("%s is not a Go release: VERSION is %q", path, line)
+	}
+	if want != "" && ref != want {
+		return fmt.Errorf("%s contains %s, not %s", path, ref, want)
+	}
+	root := filepath.Join(parent, ref)
+	rep.Version, rep.Path = ref, root
+	_, err = os.Stat(root)
+	installed := err == nil
+	if installed && !force {
+		rep.Method = "none"
+		logf("%s is already installed", ref)
+		return nil
+	}
+	if err := writeOrigin(tmp, "sha256", sum); err != nil {
+		return err
+	}
+	// The archive may be for another platform, or truncated;
+	// it goes in under a host version name, so it must run here.
+	// Check before removing any version it replaces.
+	if err := verifyTree(tmp, path); err != nil {
+		return fmt.Errorf("could not install %s: %v", ref, err)
+	}
+	if installed {
+		vlogf("removing %s", root)
+		if err := os.RemoveAll(root); err != nil {
+			return fmt.Errorf("could not remove %s: %v", root, err)
+		}
+	}
+	if err := os.Rename(tmp, root); err != nil {
+		return fmt.Errorf("could not install %s: %v", ref, err)
+	}
+	rep.Method, rep.SHA256 = "binary", sum
+	rep.timed("unpack", start)
+	logEventf(logEvent{Level: "info", Version: ref, Path: path}, "installed %s from %s", ref, path)
+	return nil
+}
+
+// installTarballJSON is installTarball for install -json:
+// it prints an installReport of the outcome.
+func installTarballJSON(ctx context.Context, path, want string, opt *installOptions) error {
+	rep := &installReport{Version: want}
+	o := *opt
+	o.report = rep
+	start := time.Now()
+	err := installTarball(ctx, path, want, &o)
+	rep.finish(start, err)
+	if err := json.NewEncoder(os.Stdout).Encode(rep); err != nil {
+		return err
+	}
+	return err
+}
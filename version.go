@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"github.com/josharian/goversion/toolchain"
+)
+
+// errNotVersion is returned by version for strings that don't look like Go versions.
+var errNotVersion = toolchain.ErrNotVersion
+
+// version is like toolchain.Normalize, but also resolves the special version latest
+// to the newest stable release, and aliases made by goversion alias to the versions they name.
+func version(ctx context.Context, s string) (string, error) {
+	if s == "latest" {
+		return latest(ctx)
+	}
+	ref, err := toolchain.Normalize(s)
+	if err != errNotVersion {
+		return ref, err
+	}
+	if ref, ok, err := expandAlias(ctx, s); ok || err != nil {
+		return ref, err
+	}
+	return "", errNotVersion
+}
+
+// versionArg is like version, for command-line arguments:
+// if s is not a Go version, it prints usage and exits.
+func versionArg(ctx context.Context, s string) (string, error) {
+	ref, err := version(ctx, s)
+	if err == errNotVersion {
+		printUsage()
+	}
+	return ref, err
+}